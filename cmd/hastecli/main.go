@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"time"
 
 	"goduplicate/internal/core"
 )
@@ -13,23 +20,99 @@ import (
 func main() {
 	var includePathsArg string
 	var excludePatternsArg string
+	var includePatternsArg string
 	var mode string
+	var listModes bool
 	var concurrency int
+	var ioConcurrency int
+	var hashConcurrency int
+	var perDevice int
 	var minSize int64
 	var maxSize int64
+	var extArg string
+	var excludeExtArg string
+	var modifiedAfter string
+	var modifiedBefore string
+	var typesArg string
+	var skipHidden bool
+	var skipSystem bool
 	var hashAlg string
+	var perceptual string
+	var clustering string
+	var invariant bool
+	var trimBorders bool
 	var sim float64
+	var verify bool
+	var symlinks string
+	var dirs bool
+	var archives bool
+	var ndjson bool
+	var showProgress bool
+	var useCache bool
+	var cacheFile string
+	var cacheStats bool
+	var cachePrune bool
+	var cacheMaxAge time.Duration
+	var cacheVerify bool
+	var watch bool
+	var watchDebounce time.Duration
+	var watchPolicy string
 
 	flag.StringVar(&includePathsArg, "paths", "", "要扫描的路径，使用;分隔多个路径")
-	flag.StringVar(&excludePatternsArg, "exclude", "", "排除的通配符模式，使用;分隔")
-	flag.StringVar(&mode, "mode", "basic", "扫描模式：basic|video|text|image")
+	flag.StringVar(&excludePatternsArg, "exclude", "", "排除规则(gitignore 语法，支持 **、目录/、!取反、/锚定)，使用;分隔；各目录下的 "+core.IgnoreFileName+" 文件同样生效")
+	flag.StringVar(&includePatternsArg, "include", "", "仅扫描匹配的文件(gitignore 语法)，使用;分隔")
+	flag.StringVar(&mode, "mode", core.DefaultMode, "扫描模式："+strings.Join(core.EngineModes(), "|"))
+	flag.BoolVar(&listModes, "list-modes", false, "列出可用的扫描模式及其选项后退出")
 	flag.IntVar(&concurrency, "concurrency", 4, "并发度")
+	flag.IntVar(&ioConcurrency, "io", 0, "同时读取的文件数上限(0为同并发度)")
+	flag.IntVar(&hashConcurrency, "hash-workers", 0, "同时计算哈希的数量上限(0为同并发度)")
+	flag.IntVar(&perDevice, "per-device", 0, "每个磁盘同时读取的文件数上限(0为不限，机械硬盘建议1)")
 	flag.Int64Var(&minSize, "min-size", 0, "最小文件大小(字节)")
 	flag.Int64Var(&maxSize, "max-size", 0, "最大文件大小(字节，0为不限)")
-	flag.StringVar(&hashAlg, "hash", "sha1", "哈希算法：sha1|sha256|md5(占位)")
-	flag.Float64Var(&sim, "similarity", 0.0, "相似度阈值(0.0-1.0，占位)")
+	flag.StringVar(&extArg, "ext", "", "仅扫描这些扩展名，使用;分隔(如 jpg;png;tar.gz)")
+	flag.StringVar(&excludeExtArg, "exclude-ext", "", "跳过这些扩展名，使用;分隔")
+	flag.StringVar(&modifiedAfter, "modified-after", "", "仅扫描此时间之后修改的文件(YYYY-MM-DD[ HH:MM])")
+	flag.StringVar(&modifiedBefore, "modified-before", "", "仅扫描此时间之前修改的文件(YYYY-MM-DD[ HH:MM])")
+	flag.StringVar(&typesArg, "types", "", "按内容识别的文件类型，使用;分隔："+joinClasses(core.FileClasses()))
+	flag.BoolVar(&skipHidden, "skip-hidden", false, "跳过隐藏文件和目录")
+	flag.BoolVar(&skipSystem, "skip-system", false, "跳过系统文件和目录(Windows)")
+	flag.StringVar(&hashAlg, "hash", core.DefaultHashAlgorithm, "哈希算法："+strings.Join(core.HasherNames(), "|"))
+	flag.Float64Var(&sim, "similarity", 0.0, "相似度阈值(0.0-1.0，用于 text/image/video 模式；0 使用模式默认值)")
+	flag.StringVar(&perceptual, "phash", core.DefaultPerceptualHash, "image/video 模式的感知哈希："+strings.Join(core.PerceptualHasherNames(), "|"))
+	flag.StringVar(&clustering, "cluster", string(core.ClusterComponents), "image/video 模式的分组方式：components(相似链连通)|complete(组内两两相似)|star(围绕中心文件)")
+	flag.BoolVar(&invariant, "invariant", false, "image 模式：旋转、镜像后的副本也算相似，并先按 EXIF 方向摆正")
+	flag.BoolVar(&trimBorders, "trim-borders", false, "image 模式：计算哈希前裁掉纯色边框和黑边")
+	flag.BoolVar(&verify, "verify", false, "哈希相同后再逐字节比对确认")
+	flag.StringVar(&symlinks, "symlinks", string(core.SymlinksIgnore), "符号链接处理：ignore(忽略)|follow(跟随，检测循环)|report(列出链接及目标)")
+	flag.BoolVar(&dirs, "dirs", false, "检测重复目录(内容完全相同或为另一目录的子集)，并把其中的文件组归入目录")
+	flag.BoolVar(&showProgress, "progress", true, "在终端的标准错误上显示进度条(阶段、百分比、速度、剩余时间)")
+	flag.BoolVar(&ndjson, "ndjson", false, "每确认一个重复组即向标准输出写一行 JSON(NDJSON)，摘要与错误写到标准错误")
+	flag.BoolVar(&archives, "archives", false, "basic 模式下把 zip/tar/tar.gz 内的文件作为虚拟文件(archive.zip!/dir/file)参与比对")
+	flag.BoolVar(&useCache, "cache", false, "使用持久哈希缓存，未变化的文件不再重新读取")
+	flag.StringVar(&cacheFile, "cache-file", core.DefaultHashIndexPath(), "哈希缓存文件路径")
+	flag.BoolVar(&cacheStats, "cache-stats", false, "显示哈希缓存统计后退出")
+	flag.BoolVar(&cachePrune, "cache-prune", false, "清理已删除/已变化的缓存条目后退出")
+	flag.DurationVar(&cacheMaxAge, "cache-max-age", 0, "与 --cache-prune 一起使用：同时清理超过该时长未见的条目(如 720h)")
+	flag.BoolVar(&cacheVerify, "cache-verify", false, "重新计算哈希校验缓存条目后退出")
+	flag.BoolVar(&watch, "watch", false, "持续监视路径，报告与已有文件重复的新文件(总是使用哈希缓存)，Ctrl+C 结束")
+	flag.DurationVar(&watchDebounce, "watch-debounce", core.DefaultWatchDebounce, "与 --watch 一起使用：变化停止多久后再检查")
+	flag.StringVar(&watchPolicy, "watch-policy", "", "与 --watch 一起使用：对新重复文件自动执行的策略预设名称，日志保存到执行日志目录")
 	flag.Parse()
 
+	if cacheStats || cachePrune || cacheVerify {
+		os.Exit(runCacheCommand(cacheFile, cacheStats, cachePrune, cacheMaxAge, cacheVerify))
+	}
+
+	if listModes {
+		printModes(os.Stdout)
+		return
+	}
+	info, err := core.LookupEngine(mode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "--mode: %v\n", err)
+		os.Exit(2)
+	}
+
 	if includePathsArg == "" {
 		fmt.Println("请使用 --paths 指定至少一个路径（使用;分隔）")
 		os.Exit(2)
@@ -37,32 +120,299 @@ func main() {
 
 	includePaths := splitAndTrim(includePathsArg)
 	excludePatterns := splitAndTrim(excludePatternsArg)
+	includePatterns := splitAndTrim(includePatternsArg)
+	after, err := core.ParseTimeBound(modifiedAfter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "--modified-after: %v\n", err)
+		os.Exit(2)
+	}
+	before, err := core.ParseTimeBound(modifiedBefore)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "--modified-before: %v\n", err)
+		os.Exit(2)
+	}
+	var classes []core.FileClass
+	for _, c := range splitAndTrim(typesArg) {
+		classes = append(classes, core.FileClass(strings.ToLower(c)))
+	}
 
 	cfg := core.ScanConfig{
 		IncludePaths:        includePaths,
 		ExcludePatterns:     excludePatterns,
-		Mode:                strings.ToLower(mode),
+		IncludePatterns:     includePatterns,
+		Mode:                info.Mode,
 		Concurrency:         concurrency,
+		IOConcurrency:       ioConcurrency,
+		HashConcurrency:     hashConcurrency,
+		PerDeviceIO:         perDevice,
 		MinSizeBytes:        minSize,
 		MaxSizeBytes:        maxSize,
+		IncludeExtensions:   splitAndTrim(extArg),
+		ExcludeExtensions:   splitAndTrim(excludeExtArg),
+		ModifiedAfterUnix:   after,
+		ModifiedBeforeUnix:  before,
+		TypeClasses:         classes,
+		SkipHidden:          skipHidden,
+		SkipSystem:          skipSystem,
 		HashAlgorithm:       strings.ToLower(hashAlg),
 		SimilarityThreshold: sim,
+		VerifyBytes:         verify,
+		PerceptualHash:      strings.ToLower(perceptual),
+		Clustering:          core.ClusterMethod(strings.ToLower(clustering)),
+		RotationInvariant:   invariant,
+		TrimBorders:         trimBorders,
+		SymlinkPolicy:       core.SymlinkPolicy(strings.ToLower(symlinks)),
+		DetectDirectories:   dirs,
+		ScanArchives:        archives,
+	}
+	if useCache || watch {
+		idx, err := core.OpenHashIndex(cacheFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "打开哈希缓存失败: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.HashIndex = idx
+	}
+	if ndjson {
+		// groups go out as they are confirmed, so a long scan can be piped into other tools
+		enc := json.NewEncoder(os.Stdout)
+		cfg.OnGroup = func(g core.DuplicateGroup) {
+			if err := enc.Encode(g); err != nil {
+				fmt.Fprintf(os.Stderr, "写出结果失败: %v\n", err)
+			}
+		}
 	}
 
-	engine := core.NewSimpleScanner()
-	groups, err := engine.Scan(cfg)
-	if err != nil {
+	// Ctrl+C stops the scan and prints what was confirmed so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if watch {
+		os.Exit(runWatch(ctx, cfg, watchDebounce, watchPolicy, ndjson))
+	}
+
+	drawing := showProgress && isTerminal(os.Stderr)
+	if drawing {
+		cfg.OnProgress = func(p core.Progress) { drawProgress(os.Stderr, p) }
+	}
+
+	engine := info.New()
+	groups, report, err := engine.ScanContext(ctx, cfg)
+	if drawing {
+		clearProgress(os.Stderr)
+	}
+	cancelled := errors.Is(err, context.Canceled)
+	if err != nil && !cancelled {
 		fmt.Fprintf(os.Stderr, "扫描失败: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Printf("发现重复组数: %d\n", len(groups))
-	for i, g := range groups {
+	if ndjson {
+		if cancelled {
+			fmt.Fprintln(os.Stderr, "扫描已取消，结果不完整")
+		}
+		fmt.Fprintf(os.Stderr, "发现分组数: %d\n", len(groups))
+		printReport(os.Stderr, report)
+		if cancelled {
+			stop()
+			os.Exit(130)
+		}
+		return
+	}
+	if cancelled {
+		fmt.Println("扫描已取消，以下结果不完整")
+	}
+	var dups, prefixes, linked, symlinked, dirGroups []core.DuplicateGroup
+	var reclaimable int64
+	for _, g := range groups {
+		switch g.Kind {
+		case core.MatchPrefix:
+			prefixes = append(prefixes, g)
+		case core.MatchHardlink:
+			linked = append(linked, g)
+		case core.MatchSymlink:
+			symlinked = append(symlinked, g)
+		case core.MatchDirectory, core.MatchDirSubset:
+			dirGroups = append(dirGroups, g)
+			reclaimable += g.ReclaimableBytes
+		default:
+			dups = append(dups, g)
+			reclaimable += g.ReclaimableBytes
+		}
+	}
+	fmt.Printf("发现重复组数: %d (可释放 %d 字节)\n", len(dups), reclaimable)
+	if len(dirGroups) > 0 {
+		fmt.Printf("重复目录: %d\n", len(dirGroups))
+		for i, g := range dirGroups {
+			if i >= 10 {
+				fmt.Println("...更多结果已省略")
+				break
+			}
+			if g.Kind == core.MatchDirSubset {
+				fmt.Printf("  %s ⊂ %s (%d 字节, 含 %d 个文件组)\n", g.Files[1].Path, g.Files[0].Path, g.Files[1].SizeBytes, len(g.Collapsed))
+				continue
+			}
+			paths := make([]string, len(g.Files))
+			for j, f := range g.Files {
+				paths[j] = f.Path
+			}
+			fmt.Printf("  %s (%d 字节, 含 %d 个文件组)\n", strings.Join(paths, " = "), g.Files[0].SizeBytes, len(g.Collapsed))
+		}
+	}
+	for i, g := range dups {
 		if i >= 10 {
 			fmt.Println("...更多结果已省略")
 			break
 		}
-		fmt.Printf("组 %d (id=%s, 文件数=%d)\n", i+1, g.GroupID[:8], len(g.Files))
+		if g.Kind == core.MatchPerceptual || g.Kind == core.MatchText {
+			fmt.Printf("组 %d (id=%s, 文件数=%d, 相似度=%.0f%%)\n", i+1, shortID(g.GroupID), len(g.Files), g.Similarity*100)
+		} else {
+			fmt.Printf("组 %d (id=%s, 文件数=%d)\n", i+1, shortID(g.GroupID), len(g.Files))
+		}
+	}
+	if len(prefixes) > 0 {
+		fmt.Printf("仅头尾相同的候选组(非重复): %d\n", len(prefixes))
+		for i, g := range prefixes {
+			if i >= 10 {
+				fmt.Println("...更多结果已省略")
+				break
+			}
+			fmt.Printf("候选 %d (文件数=%d)\n", i+1, len(g.Files))
+			for _, f := range g.Files {
+				fmt.Printf("  %s\n", f.Path)
+			}
+		}
+	}
+	if len(linked) > 0 {
+		fmt.Printf("已是硬链接的文件(同一份数据): %d\n", len(linked))
+		for i, g := range linked {
+			if i >= 10 {
+				fmt.Println("...更多结果已省略")
+				break
+			}
+			fmt.Printf("  %s\n", strings.Join(g.Files[0].Paths(), " = "))
+		}
+	}
+	if len(symlinked) > 0 {
+		fmt.Printf("符号链接: %d\n", len(symlinked))
+		for i, g := range symlinked {
+			if i >= 10 {
+				fmt.Println("...更多结果已省略")
+				break
+			}
+			fmt.Printf("  %s -> %s\n", g.Files[0].Path, g.Files[0].LinkTarget)
+		}
+	}
+	printReport(os.Stdout, report)
+	if cancelled {
+		stop()
+		os.Exit(130)
+	}
+}
+
+// runCacheCommand handles the --cache-* maintenance flags and returns the exit code.
+func runCacheCommand(path string, stats, prune bool, maxAge time.Duration, verify bool) int {
+	idx, err := core.OpenHashIndex(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开哈希缓存失败: %v\n", err)
+		return 1
+	}
+	if prune {
+		n, err := idx.Prune(maxAge)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "清理失败: %v\n", err)
+			return 1
+		}
+		fmt.Printf("已清理条目: %d\n", n)
+	}
+	if verify {
+		res, err := idx.Verify()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "校验失败: %v\n", err)
+			return 1
+		}
+		fmt.Printf("已校验: %d, 已失效: %d, 内容不符: %d, 无法读取: %d\n", res.Checked, len(res.Stale), len(res.Mismatched), len(res.Unreadable))
+		for _, p := range res.Mismatched {
+			fmt.Printf("  内容不符: %s\n", p)
+		}
+		for _, p := range res.Unreadable {
+			fmt.Printf("  无法读取: %s\n", p)
+		}
+	}
+	if stats {
+		st := idx.Stats()
+		fmt.Printf("缓存文件: %s\n", st.Path)
+		fmt.Printf("条目数: %d (日志记录 %d, %d 字节)\n", st.Entries, st.LogRecords, st.FileBytes)
+		for alg, n := range st.ByAlgorithm {
+			fmt.Printf("  %s: %d\n", alg, n)
+		}
+		fmt.Printf("感知哈希: %d\n", st.Perceptual)
+	}
+	return 0
+}
+
+// printReport summarizes the paths that could not be checked.
+func printReport(w io.Writer, report *core.ScanReport) {
+	if report.Count() == 0 {
+		return
+	}
+	counts := report.ByCategory()
+	cats := make([]string, 0, len(counts))
+	for c, n := range counts {
+		cats = append(cats, fmt.Sprintf("%s=%d", c, n))
+	}
+	sort.Strings(cats)
+	fmt.Fprintf(w, "未能检查的路径: %d (%s)\n", report.Count(), strings.Join(cats, ", "))
+	for i, e := range report.Sorted() {
+		if i >= 10 {
+			fmt.Fprintln(w, "...更多错误已省略")
+			break
+		}
+		fmt.Fprintf(w, "  [%s/%s] %s: %s\n", e.Category, e.Stage, e.Path, e.Message)
+	}
+}
+
+// printModes lists the registered scan modes with what they report and the options they take.
+func printModes(w io.Writer) {
+	for _, mode := range core.EngineModes() {
+		info, _ := core.LookupEngine(mode)
+		fmt.Fprintf(w, "%s\t%s\n", info.Mode, info.Description)
+		c := info.Capabilities
+		var caps []string
+		for _, f := range []struct {
+			on   bool
+			name string
+		}{{c.Exact, "exact"}, {c.Similarity, "similarity"}, {c.Streaming, "streaming"}, {c.Directories, "directories"}, {c.Archives, "archives"}} {
+			if f.on {
+				caps = append(caps, f.name)
+			}
+		}
+		if len(c.Classes) > 0 {
+			caps = append(caps, "types="+joinClasses(c.Classes))
+		}
+		fmt.Fprintf(w, "  能力: %s\n", strings.Join(caps, ", "))
+		for _, o := range info.Options {
+			def := o.Default
+			if len(o.Choices) > 0 {
+				def += " [" + strings.Join(o.Choices, "|") + "]"
+			}
+			fmt.Fprintf(w, "  %-20s %-6s %s (默认 %s)\n", o.Field, o.Kind, o.Description, def)
+		}
+	}
+}
+
+func joinClasses(classes []core.FileClass) string {
+	names := make([]string, len(classes))
+	for i, c := range classes {
+		names[i] = string(c)
+	}
+	return strings.Join(names, "|")
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
 	}
+	return id
 }
 
 func splitAndTrim(s string) []string {
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"goduplicate/internal/core"
+)
+
+const progressBarWidth = 24
+
+// isTerminal reports whether f is a character device, i.e. an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// drawProgress redraws the single progress line: stage, bar, throughput, ETA and current file.
+func drawProgress(w io.Writer, p core.Progress) {
+	var b strings.Builder
+	b.WriteString("\r\033[K")
+	fmt.Fprintf(&b, "%-9s", p.Stage)
+	if p.StagePercent >= 0 {
+		filled := int(p.StagePercent / 100 * progressBarWidth)
+		fmt.Fprintf(&b, " [%s%s] %5.1f%%", strings.Repeat("#", filled), strings.Repeat("-", progressBarWidth-filled), p.StagePercent)
+		fmt.Fprintf(&b, " %s/%s", formatBytes(p.BytesHashed), formatBytes(p.BytesToHash))
+		if p.BytesPerSecond > 0 {
+			fmt.Fprintf(&b, " %s/s", formatBytes(int64(p.BytesPerSecond)))
+		}
+		if p.ETA > 0 {
+			fmt.Fprintf(&b, " 剩余 %s", p.ETA.Round(time.Second))
+		}
+	} else {
+		fmt.Fprintf(&b, " 文件 %d (%s)", p.FilesScanned, formatBytes(p.BytesFound))
+	}
+	if p.GroupsFound > 0 {
+		fmt.Fprintf(&b, " 组 %d", p.GroupsFound)
+	}
+	if p.CurrentPath != "" && p.Stage != "done" && p.Stage != "cancelled" {
+		b.WriteString(" " + shortenPath(p.CurrentPath, 40))
+	}
+	fmt.Fprint(w, b.String())
+}
+
+// clearProgress erases the progress line so regular output starts on a clean line.
+func clearProgress(w io.Writer) { fmt.Fprint(w, "\r\033[K") }
+
+// shortenPath keeps the end of p, which names the file, within max runes.
+func shortenPath(p string, max int) string {
+	r := []rune(p)
+	if len(r) <= max {
+		return p
+	}
+	return "…" + string(r[len(r)-max+1:])
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
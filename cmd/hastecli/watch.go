@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"goduplicate/internal/core"
+)
+
+// runWatch monitors the configured paths until Ctrl+C and returns the exit code.
+// New duplicates are printed as they are found, or written as NDJSON to stdout.
+func runWatch(ctx context.Context, cfg core.ScanConfig, debounce time.Duration, policyName string, ndjson bool) int {
+	wc := core.WatchConfig{Scan: cfg, Debounce: debounce, Exec: core.ExecuteOptions{ConflictPolicy: core.ConflictRename}}
+	if policyName != "" {
+		preset, err := core.LoadPolicyPreset(policyName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "加载策略预设失败: %v\n", err)
+			return 1
+		}
+		wc.Policy = &preset.Policy
+	}
+	enc := json.NewEncoder(os.Stdout)
+	wc.OnEvent = func(ev core.WatchEvent) {
+		if ev.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s 监视出错: %v\n", ev.Time.Format("15:04:05"), ev.Err)
+		}
+		if ev.Initial {
+			fmt.Fprintf(os.Stderr, "%s 开始监视，已有文件 %d 个\n", ev.Time.Format("15:04:05"), ev.Files)
+			printReport(os.Stderr, ev.Report)
+			return
+		}
+		for _, g := range ev.Groups {
+			if ndjson {
+				if err := enc.Encode(g); err != nil {
+					fmt.Fprintf(os.Stderr, "写出结果失败: %v\n", err)
+				}
+				continue
+			}
+			fmt.Printf("%s 新重复 (id=%s, 大小=%d 字节)\n", ev.Time.Format("15:04:05"), shortID(g.Group.GroupID), g.Group.Files[0].SizeBytes)
+			isNew := map[string]bool{}
+			for _, f := range g.New {
+				isNew[f.Path] = true
+			}
+			for _, f := range g.Group.Files {
+				mark := " "
+				if isNew[f.Path] {
+					mark = "+"
+				}
+				fmt.Printf("  %s %s\n", mark, f.Path)
+			}
+		}
+		if ev.Exec != nil {
+			ok := 0
+			for _, e := range ev.Exec.Entries {
+				if e.Status == "success" {
+					ok++
+				} else {
+					fmt.Fprintf(os.Stderr, "  %s %s: %s %s\n", e.Action, e.Source, e.Status, e.Message)
+				}
+			}
+			fmt.Fprintf(os.Stderr, "  策略已执行 %d/%d 项，日志: %s\n", ok, len(ev.Exec.Entries), ev.LogPath)
+		}
+	}
+	err := core.Watch(ctx, wc)
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, "监视已结束")
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "监视失败: %v\n", err)
+	return 1
+}
@@ -0,0 +1,488 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ArchiveSeparator joins an archive path and a member name in the path of a virtual file,
+// as in backup.zip!/photos/a.jpg.
+const ArchiveSeparator = "!/"
+
+// isArchiveName reports whether name has an archive extension handled by ScanConfig.ScanArchives.
+func isArchiveName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func isZipName(name string) bool { return strings.HasSuffix(strings.ToLower(name), ".zip") }
+
+// memberName returns the name of a virtual file inside its archive.
+func (f FileInfo) memberName() string {
+	return strings.TrimPrefix(f.Path, f.Archive+ArchiveSeparator)
+}
+
+// cleanMemberName normalizes a member name as stored in the archive: "./a//b" becomes "a/b".
+func cleanMemberName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// expandArchives lists the regular-file members of each archive as virtual files that pass
+// filter. Nothing is hashed yet: members go through the size, sample and full hash stages like
+// files on disk, except that tar members are hashed by hashTarMembers.
+func expandArchives(lim *limits, archives []FileInfo, filter *fileFilter) []FileInfo {
+	members := make([][]FileInfo, len(archives))
+	lim.forEach(len(archives), func(i int) {
+		a := archives[i]
+		var err error
+		if isZipName(a.Path) {
+			members[i], err = listZip(lim.src, a, filter)
+		} else {
+			members[i], err = lim.listTar(a, filter)
+		}
+		if err != nil {
+			lim.reportArchive(a.Path, err)
+		}
+		lim.prog.advance(a.Path, a.SizeBytes)
+	})
+	var out []FileInfo
+	for _, m := range members {
+		out = append(out, m...)
+	}
+	return out
+}
+
+// reportArchive records an archive that could not be read: unreadable files as I/O errors,
+// anything else as a damaged archive.
+func (l *limits) reportArchive(archive string, err error) {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		l.rep.addIO(archive, "archive", err)
+	} else {
+		l.rep.add(archive, "archive", ErrorDecode, err)
+	}
+}
+
+func memberInfo(a FileInfo, name string, info fs.FileInfo) FileInfo {
+	return FileInfo{
+		Path:         a.Path + ArchiveSeparator + name,
+		SizeBytes:    info.Size(),
+		ModifiedUnix: info.ModTime().Unix(),
+		Device:       a.Device,
+		Archive:      a.Path,
+		modNano:      info.ModTime().UnixNano(),
+	}
+}
+
+func listZip(src source, a FileInfo, filter *fileFilter) ([]FileInfo, error) {
+	zr, closer, err := openZip(src, a.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	var out []FileInfo
+	seen := map[string]bool{}
+	for _, zf := range zr.File {
+		info := zf.FileInfo()
+		name := cleanMemberName(zf.Name)
+		if !info.Mode().IsRegular() || seen[name] || !filter.keep(path.Base(name), info) {
+			continue
+		}
+		seen[name] = true
+		out = append(out, memberInfo(a, name, info))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out, nil
+}
+
+// listTar reads the headers of the archive, sniffing the type of every member that passes
+// filter from its first bytes.
+func (l *limits) listTar(a FileInfo, filter *fileFilter) ([]FileInfo, error) {
+	l.acquireIO(a.Device)
+	defer l.releaseIO(a.Device)
+	tr, closer, err := openTar(l.src, a.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	var out []FileInfo
+	seen := map[string]bool{}
+	head := make([]byte, sniffBytes)
+	for {
+		if err := l.checkpoint(); err != nil {
+			return out, err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return out, err
+		}
+		info := hdr.FileInfo()
+		name := cleanMemberName(hdr.Name)
+		if !info.Mode().IsRegular() || seen[name] || !filter.keep(path.Base(name), info) {
+			continue
+		}
+		seen[name] = true
+		m := memberInfo(a, name, info)
+		n, err := io.ReadFull(tr, head)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return out, err
+		}
+		m.Type, m.Class = sniffType(head[:n], name)
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// hashTarMembers hashes the tar members among files, which groupExact passes once they share
+// a size. A tar can only be read front to back, so reading the members one at a time would
+// scan the archive again for each; instead every archive is streamed once, and each wanted
+// member yields its sample and full hash. Members hashed before or whose hashes idx holds are
+// left alone, as are members that fail: the sample stage reports them.
+func (l *limits) hashTarMembers(alg Hasher, idx *HashIndex, files []FileInfo) {
+	want := map[string]map[string]*FileInfo{}
+	var archives []*FileInfo // one member of each archive, for its path and device
+	for i := range files {
+		f := &files[i]
+		if f.Archive == "" || isZipName(f.Archive) || f.sample != "" && f.HashAlgorithm == alg.Name {
+			continue
+		}
+		if _, ok := idx.contentHash(*f, alg.Name, true); ok {
+			continue
+		}
+		members, ok := want[f.Archive]
+		if !ok {
+			members = map[string]*FileInfo{}
+			want[f.Archive] = members
+			archives = append(archives, f)
+		}
+		members[f.memberName()] = f
+	}
+	l.forEach(len(archives), func(i int) {
+		a := archives[i]
+		if err := l.streamTar(alg, a.Archive, a.Device, want[a.Archive]); err != nil && l.checkpoint() == nil {
+			l.reportArchive(a.Archive, err)
+		}
+	})
+}
+
+// streamTar reads archive until every member of want has been hashed.
+func (l *limits) streamTar(alg Hasher, archive string, device uint64, want map[string]*FileInfo) error {
+	l.acquireIO(device)
+	defer l.releaseIO(device)
+	tr, closer, err := openTar(l.src, archive)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	for len(want) > 0 {
+		if err := l.checkpoint(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := cleanMemberName(hdr.Name)
+		f, ok := want[name]
+		if !ok {
+			continue
+		}
+		delete(want, name) // the first member of a name is the one listed
+		l.cpu <- struct{}{}
+		sample, full, _, err := streamSample(alg, tr, f.SizeBytes)
+		<-l.cpu
+		l.prog.advance(f.Path, f.SizeBytes)
+		if err == nil {
+			f.sample, f.Hash, f.HashAlgorithm = sample, full, alg.Name
+		}
+	}
+	return nil
+}
+
+// openZip opens a zip archive; the closer releases the underlying file.
+func openZip(src source, archive string) (*zip.Reader, io.Closer, error) {
+	f, err := src.open(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+	ra, size, err := readerAt(f)
+	if err == nil {
+		var zr *zip.Reader
+		if zr, err = zip.NewReader(ra, size); err == nil {
+			return zr, f, nil
+		}
+	}
+	f.Close()
+	return nil, nil, err
+}
+
+// openTar opens a plain or gzip-compressed tar archive.
+func openTar(src source, archive string) (*tar.Reader, io.Closer, error) {
+	f, err := src.open(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+	lower := strings.ToLower(archive)
+	if strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tgz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return tar.NewReader(zr), f, nil
+	}
+	return tar.NewReader(f), f, nil
+}
+
+// openContent opens a file or streams a member out of its archive.
+func (s source) openContent(f FileInfo) (io.ReadCloser, error) {
+	if f.Archive == "" {
+		return s.open(f.Path)
+	}
+	name := f.memberName()
+	if isZipName(f.Archive) {
+		return s.zips.member(s, f.Archive, name, f.Path)
+	}
+	tr, closer, err := openTar(s, f.Archive)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			closer.Close()
+			if err == io.EOF {
+				return nil, &fs.PathError{Op: "open", Path: f.Path, Err: fs.ErrNotExist}
+			}
+			return nil, err
+		}
+		if cleanMemberName(hdr.Name) == name {
+			return readCloser{tr, closer}, nil
+		}
+	}
+}
+
+// maxIdleZips is how many zip archives a scan keeps open while none of their members is read.
+const maxIdleZips = 16
+
+// zipCache keeps the zip archives of a scan open with their members indexed by name, so
+// reading every member of an archive parses its directory once rather than once per member.
+// A nil cache opens the archive for each member.
+type zipCache struct {
+	mu   sync.Mutex
+	open map[string]*zipArchive
+	tick uint64
+}
+
+type zipArchive struct {
+	closer  io.Closer
+	members map[string]*zip.File // first member of each cleaned name
+	readers int                  // members being read
+	used    uint64               // tick of the last read, for closing the least recently used
+}
+
+func newZipCache() *zipCache { return &zipCache{open: map[string]*zipArchive{}} }
+
+func indexZip(src source, archive string) (*zipArchive, error) {
+	zr, closer, err := openZip(src, archive)
+	if err != nil {
+		return nil, err
+	}
+	z := &zipArchive{closer: closer, members: make(map[string]*zip.File, len(zr.File))}
+	for _, zf := range zr.File {
+		if name := cleanMemberName(zf.Name); z.members[name] == nil {
+			z.members[name] = zf
+		}
+	}
+	return z, nil
+}
+
+// member opens the member name of archive; path is the virtual file's path, for errors.
+func (c *zipCache) member(src source, archive, name, path string) (io.ReadCloser, error) {
+	if c == nil {
+		z, err := indexZip(src, archive)
+		if err != nil {
+			return nil, err
+		}
+		return z.openMember(name, path, z.closer)
+	}
+	c.mu.Lock()
+	z, ok := c.open[archive]
+	if !ok {
+		c.mu.Unlock()
+		opened, err := indexZip(src, archive)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		if z, ok = c.open[archive]; ok {
+			opened.closer.Close() // another reader indexed it meanwhile
+		} else {
+			z = opened
+			c.open[archive] = z
+		}
+	}
+	c.tick++
+	z.readers++
+	z.used = c.tick
+	c.mu.Unlock()
+	return z.openMember(name, path, closerFunc(func() error { c.release(z); return nil }))
+}
+
+// openMember opens a member; done is closed along with it, or at once if it cannot be opened.
+func (z *zipArchive) openMember(name, path string, done io.Closer) (io.ReadCloser, error) {
+	zf, ok := z.members[name]
+	if !ok {
+		done.Close()
+		return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	}
+	rc, err := zf.Open()
+	if err != nil {
+		done.Close()
+		return nil, err
+	}
+	return readCloser{rc, closers{rc, done}}, nil
+}
+
+// release ends a read of z and closes the least recently used idle archives beyond maxIdleZips.
+func (c *zipCache) release(z *zipArchive) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	z.readers--
+	for {
+		var idle []string
+		for name, a := range c.open {
+			if a.readers == 0 {
+				idle = append(idle, name)
+			}
+		}
+		if len(idle) <= maxIdleZips {
+			return
+		}
+		oldest := idle[0]
+		for _, name := range idle[1:] {
+			if c.open[name].used < c.open[oldest].used {
+				oldest = name
+			}
+		}
+		c.open[oldest].closer.Close()
+		delete(c.open, oldest)
+	}
+}
+
+// close closes every archive; the scan is over, so no member is being read.
+func (c *zipCache) close() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, z := range c.open {
+		z.closer.Close()
+		delete(c.open, name)
+	}
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var first error
+	for _, x := range c {
+		if err := x.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// streamSample reads exactly size bytes from r once and returns both the head/tail sample hash,
+// computed over the same bytes readSample would take from a file, and the full hash, plus the
+// head of the content for type sniffing.
+func streamSample(alg Hasher, r io.Reader, size int64) (sample, full string, head []byte, err error) {
+	fh := alg.New()
+	if size <= 2*sampleBytes {
+		data, err := io.ReadAll(io.LimitReader(r, size+1))
+		if err != nil {
+			return "", "", nil, err
+		}
+		if int64(len(data)) != size {
+			return "", "", nil, fmt.Errorf("member is %d bytes, header says %d", len(data), size)
+		}
+		fh.Write(data)
+		h := hexSum(fh)
+		return h, h, data, nil
+	}
+	buf := make([]byte, 2*sampleBytes)
+	if _, err := io.ReadFull(r, buf[:sampleBytes]); err != nil {
+		return "", "", nil, err
+	}
+	fh.Write(buf[:sampleBytes])
+	if _, err := io.CopyN(fh, r, size-2*sampleBytes); err != nil {
+		return "", "", nil, err
+	}
+	if _, err := io.ReadFull(r, buf[sampleBytes:]); err != nil {
+		return "", "", nil, err
+	}
+	fh.Write(buf[sampleBytes:])
+	if n, _ := r.Read(make([]byte, 1)); n > 0 {
+		return "", "", nil, fmt.Errorf("member is longer than the %d bytes its header says", size)
+	}
+	sh := alg.New()
+	sh.Write(buf)
+	return hexSum(sh), hexSum(fh), buf[:sampleBytes], nil
+}
+
+// sampleMember is sampleFile for archive members: one streaming read yields the sample and the
+// full hash, which is kept on f so the full-hash stage does not decompress the member again.
+func (l *limits) sampleMember(alg Hasher, f *FileInfo) (string, error) {
+	if f.sample != "" && f.HashAlgorithm == alg.Name {
+		return f.sample, nil
+	}
+	l.acquireIO(f.Device)
+	defer l.releaseIO(f.Device)
+	r, err := l.src.openContent(*f)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	l.cpu <- struct{}{}
+	sample, full, head, err := streamSample(alg, r, f.SizeBytes)
+	<-l.cpu
+	if err != nil {
+		return "", err
+	}
+	f.sample, f.Hash, f.HashAlgorithm = sample, full, alg.Name
+	if f.Type == "" {
+		f.Type, f.Class = sniffType(sniffHead(head), f.memberName())
+	}
+	return sample, nil
+}
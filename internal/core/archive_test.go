@@ -0,0 +1,130 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"testing/fstest"
+)
+
+// member is one file stored in a test archive.
+type member struct{ name, data string }
+
+func zipData(t *testing.T, members ...member) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, m := range members {
+		w, err := zw.Create(m.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, m.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func tarData(t *testing.T, gz bool, members ...member) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var zw *gzip.Writer
+	if gz {
+		zw = gzip.NewWriter(&buf)
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	for _, m := range members {
+		if err := tw.WriteHeader(&tar.Header{Name: m.name, Mode: 0o644, Size: int64(len(m.data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, m.data)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.String()
+}
+
+func TestArchiveMembers(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		exts  []string
+		want  []string
+	}{
+		{
+			name: "zip member and loose file",
+			files: map[string]string{
+				"a.zip": zipData(t, member{"dir/x", "content x"}, member{"y", "content y"}),
+				"x":     "content x",
+			},
+			want: []string{"exact a.zip!/dir/x x"},
+		},
+		{
+			name: "members of every format",
+			files: map[string]string{
+				"a.zip":    zipData(t, member{"x", "content x"}),
+				"b.tar":    tarData(t, false, member{"./x", "content x"}),
+				"c.tar.gz": tarData(t, true, member{"sub//x", "content x"}),
+			},
+			want: []string{"exact a.zip!/x b.tar!/x c.tar.gz!/sub/x"},
+		},
+		{
+			name: "members of one archive",
+			files: map[string]string{
+				"a.tgz": tarData(t, true, member{"x", "same"}, member{"y", "same"}, member{"z", "else"}),
+			},
+			want: []string{"exact a.tgz!/x a.tgz!/y"},
+		},
+		{
+			name: "large tar members",
+			files: map[string]string{
+				"a.tar": tarData(t, false, member{"x", large('1')}, member{"y", large('2')}),
+				"b.zip": zipData(t, member{"x", large('1')}),
+			},
+			want: []string{"prefix a.tar!/x a.tar!/y", "exact a.tar!/x b.zip!/x"},
+		},
+		{
+			name: "filters apply to members",
+			files: map[string]string{
+				"a.zip": zipData(t, member{"x.txt", "same"}, member{"x.bin", "same"}),
+				"b.txt": "same",
+			},
+			exts: []string{"txt"},
+			want: []string{"exact a.zip!/x.txt b.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, rep := scanFS(t, ExactEngine{}, textFS(tt.files), ScanConfig{IncludeExtensions: tt.exts, ScanArchives: true})
+			checkGroups(t, groups, tt.want)
+			if rep.Count() != 0 {
+				t.Errorf("errors %v", rep.Errors)
+			}
+		})
+	}
+}
+
+func TestDamagedArchivesAreReported(t *testing.T) {
+	fsys := fstest.MapFS{
+		"bad.zip": {Data: []byte("PK\x03\x04 not a zip")},
+		"bad.tgz": {Data: []byte("not gzip")},
+	}
+	_, rep := scanFS(t, ExactEngine{}, fsys, ScanConfig{ScanArchives: true})
+	counts := rep.ByCategory()
+	if rep.Count() != 2 || counts[ErrorDecode] != 2 {
+		t.Errorf("errors %v", rep.Errors)
+	}
+}
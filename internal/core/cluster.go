@@ -0,0 +1,358 @@
+package core
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+)
+
+// ClusterMethod selects how image and video modes turn pairs of similar files into groups.
+type ClusterMethod string
+
+const (
+	// ClusterComponents groups files connected by a chain of similar pairs, so A~B and B~C put
+	// A, B and C together even when A and C are further apart than the threshold (default).
+	ClusterComponents ClusterMethod = "components"
+	// ClusterComplete keeps only groups in which every pair is within the threshold, merging the
+	// closest groups first (complete linkage).
+	ClusterComplete ClusterMethod = "complete"
+	// ClusterStar builds each group around a centre, the file with the most similar files left,
+	// and places it first; every member is within the threshold of the centre.
+	ClusterStar ClusterMethod = "star"
+)
+
+// ClusterMethods lists the supported clustering methods, the default first.
+func ClusterMethods() []ClusterMethod {
+	return []ClusterMethod{ClusterComponents, ClusterComplete, ClusterStar}
+}
+
+func checkClusterMethod(m ClusterMethod) (ClusterMethod, error) {
+	if m == "" {
+		return ClusterComponents, nil
+	}
+	for _, known := range ClusterMethods() {
+		if m == known {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown clustering %q (available: components, complete, star)", m)
+}
+
+// hashEdge is a pair of similar files, by position in the hashed files, with a < b. turn is
+// the index in transforms of the orientation of a that matched b.
+type hashEdge struct {
+	a, b int32
+	bits int
+	turn uint8
+}
+
+func pairKey(a, b int32) uint64 { return uint64(a)<<32 | uint64(b) }
+
+// clusterByHash groups files whose perceptual hashes differ in at most threshold bits. The pairs
+// within the threshold are found once through a hammingIndex on the worker pool; method then
+// decides how pairs become groups. Each group lists the distances measured between its members.
+// A hash may be followed by those of the other orientations, as imageHashing writes them; they
+// are matched against the upright hashes, and the closest orientation of a pair is reported.
+// Groups and their files follow the order of files, which scans keep sorted by path, so the
+// result does not depend on scheduling. Files with an empty hash are skipped; grouped files
+// carry their upright hash, made by algorithm.
+func clusterByHash(lim *limits, files []FileInfo, hashes []string, algorithm string, threshold int, method ClusterMethod) []DuplicateGroup {
+	var hashed []FileInfo
+	var values [][]uint64
+	for i, f := range files {
+		if hs, ok := parseHashes(hashes[i]); ok {
+			f.Hash, f.HashAlgorithm = hashes[i][:16], algorithm
+			hashed = append(hashed, f)
+			values = append(values, hs)
+		}
+	}
+	idx := newHammingIndex(len(values), threshold)
+	for _, hs := range values {
+		idx.add(hs[0])
+	}
+	near := make([][]hashEdge, len(values))
+	lim.forEach(len(values), func(i int) {
+		for t, h := range values[i] {
+			idx.within(h, func(j, d int) {
+				if j > i {
+					near[i] = append(near[i], hashEdge{int32(i), int32(j), d, uint8(t)})
+				}
+			})
+		}
+	})
+	var edges []hashEdge
+	for i := range near {
+		// one edge per pair: the closest orientation, the upright one on a tie
+		e := near[i]
+		sort.Slice(e, func(x, y int) bool {
+			if e[x].b != e[y].b {
+				return e[x].b < e[y].b
+			}
+			if e[x].bits != e[y].bits {
+				return e[x].bits < e[y].bits
+			}
+			return e[x].turn < e[y].turn
+		})
+		for k := range e {
+			if k == 0 || e[k].b != e[k-1].b {
+				edges = append(edges, e[k])
+			}
+		}
+	}
+
+	var clusters [][]int32
+	switch method {
+	case ClusterComplete:
+		clusters = completeLinkage(len(values), edges)
+	case ClusterStar:
+		clusters = starClusters(len(values), edges)
+	default:
+		clusters = components(len(values), edges)
+	}
+	// by earliest member; a star lists its centre first, which need not be the earliest
+	first := func(c []int32) int32 {
+		m := c[0]
+		for _, x := range c {
+			if x < m {
+				m = x
+			}
+		}
+		return m
+	}
+	sort.Slice(clusters, func(i, j int) bool { return first(clusters[i]) < first(clusters[j]) })
+
+	dist := make(map[uint64]hashEdge, len(edges))
+	for _, e := range edges {
+		dist[pairKey(e.a, e.b)] = e
+	}
+	out := make([]DuplicateGroup, 0, len(clusters))
+	for _, c := range clusters {
+		g := DuplicateGroup{GroupID: hashed[c[0]].Path, Kind: MatchPerceptual, Files: make([]FileInfo, len(c))}
+		total := 0
+		for x := range c {
+			g.Files[x] = hashed[c[x]]
+			for y := x + 1; y < len(c); y++ {
+				a, b := c[x], c[y]
+				if a > b {
+					a, b = b, a
+				}
+				if e, ok := dist[pairKey(a, b)]; ok {
+					t := transforms[e.turn]
+					if a != c[x] {
+						t = t.inverse()
+					}
+					g.Distances = append(g.Distances, PairDistance{A: x, B: y, Bits: e.bits, Transform: t})
+					total += e.bits
+				}
+			}
+		}
+		g.Similarity = 1 - float64(total)/float64(len(g.Distances))/64
+		out = append(out, g)
+	}
+	return out
+}
+
+// setsOf lists the sets with at least two members, each in position order, ordered by their
+// first member.
+func setsOf(u *unionFind) [][]int32 {
+	byRoot := map[int32][]int32{}
+	var roots []int32
+	for i := range u.parent {
+		r := int32(u.find(i))
+		if _, ok := byRoot[r]; !ok {
+			roots = append(roots, r)
+		}
+		byRoot[r] = append(byRoot[r], int32(i))
+	}
+	var out [][]int32
+	for _, r := range roots {
+		if len(byRoot[r]) >= 2 {
+			out = append(out, byRoot[r])
+		}
+	}
+	return out
+}
+
+// components returns the connected components of the similarity graph.
+func components(n int, edges []hashEdge) [][]int32 {
+	u := newUnionFind(n)
+	for _, e := range edges {
+		u.union(int(e.a), int(e.b))
+	}
+	return setsOf(u)
+}
+
+// linkCandidate is a queued merge of the clusters holding a and b, at the linkage it had when
+// queued; the linkage of a pair of clusters only grows as they absorb others.
+type linkCandidate struct {
+	bits int
+	a, b int32
+}
+
+type linkQueue []linkCandidate
+
+func (q linkQueue) Len() int { return len(q) }
+func (q linkQueue) Less(i, j int) bool {
+	if q[i].bits != q[j].bits {
+		return q[i].bits < q[j].bits
+	}
+	if q[i].a != q[j].a {
+		return q[i].a < q[j].a
+	}
+	return q[i].b < q[j].b
+}
+func (q linkQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *linkQueue) Push(x interface{}) { *q = append(*q, x.(linkCandidate)) }
+func (q *linkQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// completeLinkage merges clusters closest first, where the distance of two clusters is that of
+// their furthest pair, and never merges clusters with a pair beyond the threshold. Candidates
+// are re-queued lazily: a popped candidate whose clusters have since grown is checked against
+// their current linkage and queued again if that is larger.
+func completeLinkage(n int, edges []hashEdge) [][]int32 {
+	dist := make(map[uint64]int, len(edges))
+	q := make(linkQueue, 0, len(edges))
+	for _, e := range edges {
+		dist[pairKey(e.a, e.b)] = e.bits
+		q = append(q, linkCandidate{e.bits, e.a, e.b})
+	}
+	heap.Init(&q)
+	u := newUnionFind(n)
+	members := map[int32][]int32{}
+	membersOf := func(root int32) []int32 {
+		if m, ok := members[root]; ok {
+			return m
+		}
+		return []int32{root}
+	}
+	for q.Len() > 0 {
+		c := heap.Pop(&q).(linkCandidate)
+		ra, rb := int32(u.find(int(c.a))), int32(u.find(int(c.b)))
+		if ra == rb {
+			continue
+		}
+		// linkage of the current clusters; a missing pair is beyond the threshold for good
+		link, ok := 0, true
+		for _, x := range membersOf(ra) {
+			for _, y := range membersOf(rb) {
+				a, b := x, y
+				if a > b {
+					a, b = b, a
+				}
+				d, found := dist[pairKey(a, b)]
+				if !found {
+					ok = false
+					break
+				}
+				if d > link {
+					link = d
+				}
+			}
+			if !ok {
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		if link > c.bits {
+			heap.Push(&q, linkCandidate{link, c.a, c.b})
+			continue
+		}
+		merged := append(append([]int32(nil), membersOf(ra)...), membersOf(rb)...)
+		delete(members, ra)
+		delete(members, rb)
+		u.union(int(ra), int(rb))
+		members[int32(u.find(int(ra)))] = merged
+	}
+	return setsOf(u)
+}
+
+// starCandidate is a queued centre with its count of unassigned similar files and their summed
+// distance when queued; both only shrink as files are assigned.
+type starCandidate struct {
+	degree, bits int
+	pos          int32
+}
+
+type starQueue []starCandidate
+
+func (q starQueue) Len() int { return len(q) }
+func (q starQueue) Less(i, j int) bool {
+	if q[i].degree != q[j].degree {
+		return q[i].degree > q[j].degree
+	}
+	if q[i].bits != q[j].bits {
+		return q[i].bits < q[j].bits
+	}
+	return q[i].pos < q[j].pos
+}
+func (q starQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *starQueue) Push(x interface{}) { *q = append(*q, x.(starCandidate)) }
+func (q *starQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// starClusters repeatedly takes as centre the unassigned file with the most unassigned similar
+// files, preferring the smaller summed distance and then the earlier position, and groups those
+// files around it. Candidates are re-queued lazily when their counts have dropped.
+func starClusters(n int, edges []hashEdge) [][]int32 {
+	type neighbour struct {
+		pos  int32
+		bits int
+	}
+	adj := make([][]neighbour, n)
+	for _, e := range edges {
+		adj[e.a] = append(adj[e.a], neighbour{e.b, e.bits})
+		adj[e.b] = append(adj[e.b], neighbour{e.a, e.bits})
+	}
+	assigned := make([]bool, n)
+	open := func(i int32) (degree, bits int) {
+		for _, nb := range adj[i] {
+			if !assigned[nb.pos] {
+				degree++
+				bits += nb.bits
+			}
+		}
+		return degree, bits
+	}
+	var q starQueue
+	for i := range adj {
+		if d, b := open(int32(i)); d > 0 {
+			q = append(q, starCandidate{d, b, int32(i)})
+		}
+	}
+	heap.Init(&q)
+	var out [][]int32
+	for q.Len() > 0 {
+		c := heap.Pop(&q).(starCandidate)
+		if assigned[c.pos] {
+			continue
+		}
+		if d, b := open(c.pos); d != c.degree || b != c.bits {
+			if d > 0 {
+				heap.Push(&q, starCandidate{d, b, c.pos})
+			}
+			continue
+		}
+		assigned[c.pos] = true
+		var rest []int32
+		for _, nb := range adj[c.pos] {
+			if !assigned[nb.pos] {
+				assigned[nb.pos] = true
+				rest = append(rest, nb.pos)
+			}
+		}
+		sort.Slice(rest, func(i, j int) bool { return rest[i] < rest[j] })
+		out = append(out, append([]int32{c.pos}, rest...))
+	}
+	return out
+}
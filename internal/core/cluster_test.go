@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestClusterByHash(t *testing.T) {
+	// a chain: a-b 2 bits, b-c 3, c-d 2; every other pair is beyond the threshold of 4
+	const a = uint64(0)
+	b := a ^ 0b11
+	c := b ^ 0b11100
+	d := c ^ 0b11_0000_0000
+	files := []FileInfo{{Path: "a"}, {Path: "b"}, {Path: "c"}, {Path: "d"}, {Path: "unhashed"}}
+	hashes := []string{hexHash(a), hexHash(b), hexHash(c), hexHash(d), ""}
+	tests := []struct {
+		method ClusterMethod
+		want   []string
+		bits   [][]int // distances listed for each group
+	}{
+		{ClusterComponents, []string{"perceptual a b c d"}, [][]int{{2, 3, 2}}},
+		{ClusterComplete, []string{"perceptual a b", "perceptual c d"}, [][]int{{2}, {2}}},
+		{ClusterStar, []string{"perceptual b a c"}, [][]int{{2, 3}}},
+	}
+	lim := newLimits(context.Background(), ScanConfig{}, nil)
+	for _, tt := range tests {
+		groups := clusterByHash(lim, files, hashes, "test", 4, tt.method)
+		if got := describe(groups); !equalStrings(got, tt.want) {
+			t.Errorf("%s: groups %q, want %q", tt.method, got, tt.want)
+			continue
+		}
+		for i, g := range groups {
+			var got []int
+			for _, pd := range g.Distances {
+				got = append(got, pd.Bits)
+			}
+			if !equalInts(got, tt.bits[i]) {
+				t.Errorf("%s: group %d distances %v, want %v", tt.method, i, got, tt.bits[i])
+			}
+			if g.GroupID != g.Files[0].Path || g.Files[0].HashAlgorithm != "test" {
+				t.Errorf("%s: group %q led by %q, hashed by %q", tt.method, g.GroupID, g.Files[0].Path, g.Files[0].HashAlgorithm)
+			}
+		}
+	}
+}
+
+func TestClusterByHashMatchesOrientations(t *testing.T) {
+	// the hashes of the eight orientations of x, and of y, which is x turned a quarter and a
+	// bit off: y upright is close to x rotated 90, and x upright to y rotated 270
+	orientations := func(hs [8]uint64) string {
+		var sb strings.Builder
+		for _, h := range hs {
+			sb.WriteString(hexHash(h))
+		}
+		return sb.String()
+	}
+	var x, y [8]uint64
+	for i := range x {
+		x[i] = uint64(0x0101010101010101) << uint(i)
+		y[i] = ^x[i] // far from everything else
+	}
+	y[0], y[3] = x[1]^1, x[0]^1
+	tests := []struct {
+		name  string
+		files []FileInfo
+		hs    []string
+		want  Transform // from Files[0] to Files[1]
+	}{
+		{"x first", []FileInfo{{Path: "x"}, {Path: "y"}}, []string{orientations(x), orientations(y)}, TransformRotate90},
+		{"y first", []FileInfo{{Path: "y"}, {Path: "x"}}, []string{orientations(y), orientations(x)}, TransformRotate270},
+	}
+	lim := newLimits(context.Background(), ScanConfig{}, nil)
+	for _, tt := range tests {
+		groups := clusterByHash(lim, tt.files, tt.hs, "test", 4, ClusterComponents)
+		if len(groups) != 1 || len(groups[0].Distances) != 1 {
+			t.Fatalf("%s: groups %q", tt.name, describe(groups))
+		}
+		if pd := groups[0].Distances[0]; pd.Bits != 1 || pd.Transform != tt.want {
+			t.Errorf("%s: %d bits by %q, want 1 by %q", tt.name, pd.Bits, pd.Transform, tt.want)
+		}
+		if h := groups[0].Files[0].Hash; h != tt.hs[0][:16] {
+			t.Errorf("%s: hash %q, want the upright one", tt.name, h)
+		}
+	}
+}
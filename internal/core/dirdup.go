@@ -0,0 +1,370 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// minDirFiles is the smallest number of files a directory needs to be reported as a duplicate;
+// single files are already covered by their file group.
+const minDirFiles = 2
+
+// dirNode is one scanned directory with a Merkle hash over its names and contents.
+type dirNode struct {
+	path    string
+	parent  *dirNode
+	files   map[string]string // name -> content token
+	subdirs []*dirNode
+	hash    string
+	count   int   // files below, recursively
+	bytes   int64 // bytes below, recursively
+	mod     int64 // newest file modification below
+	depth   int
+	tokens  map[string]int // content multiset below; built on demand for subset checks
+}
+
+// groupDirectories finds directories whose scanned contents are identical (same names and
+// content, recursively) and directories whose files all also exist in another directory.
+// Each such pair or set becomes a MatchDirectory or MatchDirSubset group, and the file groups
+// lying entirely inside it move to its Collapsed list. A directory is only reported when every
+// entry in it was scanned, so acting on it never touches files the scan did not examine.
+func groupDirectories(src source, roots []string, files []FileInfo, groups []DuplicateGroup) []DuplicateGroup {
+	// content token per path: files of one exact group share it, anything else is unique
+	token := map[string]string{}
+	for _, g := range groups {
+		if g.Kind != MatchExact {
+			continue
+		}
+		for _, f := range g.Files {
+			for _, p := range f.Paths() {
+				token[p] = "g:" + g.GroupID
+			}
+		}
+	}
+
+	rootSet := map[string]bool{}
+	for _, r := range roots {
+		rootSet[filepath.Clean(r)] = true
+	}
+	nodes := map[string]*dirNode{}
+	var getNode func(dir string) *dirNode
+	getNode = func(dir string) *dirNode {
+		if n, ok := nodes[dir]; ok {
+			return n
+		}
+		n := &dirNode{path: dir, files: map[string]string{}}
+		nodes[dir] = n
+		if up := filepath.Dir(dir); !rootSet[dir] && up != dir {
+			n.parent = getNode(up)
+			n.parent.subdirs = append(n.parent.subdirs, n)
+		}
+		return n
+	}
+	known := map[string]bool{} // every scanned path, for the completeness check
+	for _, f := range files {
+		if f.Archive != "" {
+			continue // archive members are not in any directory on disk
+		}
+		for _, p := range f.Paths() {
+			t, ok := token[p]
+			if !ok {
+				t = "u:" + f.Path // hardlinks of one unique file still share content
+			}
+			known[p] = true
+			n := getNode(filepath.Dir(p))
+			n.files[filepath.Base(p)] = t
+			for a := n; a != nil; a = a.parent {
+				a.count++
+				a.bytes += f.SizeBytes
+				if f.ModifiedUnix > a.mod {
+					a.mod = f.ModifiedUnix
+				}
+			}
+		}
+	}
+
+	// hash bottom-up: deeper directories first
+	all := make([]*dirNode, 0, len(nodes))
+	for _, n := range nodes {
+		for a := n.parent; a != nil; a = a.parent {
+			n.depth++
+		}
+		all = append(all, n)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].depth != all[j].depth {
+			return all[i].depth > all[j].depth
+		}
+		return all[i].path < all[j].path
+	})
+	for _, n := range all {
+		lines := make([]string, 0, len(n.files)+len(n.subdirs))
+		for name, t := range n.files {
+			lines = append(lines, "f\x00"+name+"\x00"+t)
+		}
+		for _, s := range n.subdirs {
+			lines = append(lines, "d\x00"+filepath.Base(s.path)+"\x00"+s.hash)
+		}
+		sort.Strings(lines)
+		h := sha256.New()
+		for _, l := range lines {
+			h.Write([]byte(l))
+			h.Write([]byte{'\n'})
+		}
+		n.hash = hex.EncodeToString(h.Sum(nil))
+	}
+	// shallow directories first from here on, so the outermost duplicate wins
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].depth != all[j].depth {
+			return all[i].depth < all[j].depth
+		}
+		return all[i].path < all[j].path
+	})
+
+	complete := map[*dirNode]bool{}
+	var isComplete func(n *dirNode) bool
+	isComplete = func(n *dirNode) bool {
+		if c, ok := complete[n]; ok {
+			return c
+		}
+		c := dirComplete(src, n, known, isComplete, nodes)
+		complete[n] = c
+		return c
+	}
+
+	covered := map[*dirNode]bool{} // inside a reported directory
+	cover := func(n *dirNode) {
+		var mark func(d *dirNode)
+		mark = func(d *dirNode) {
+			for _, s := range d.subdirs {
+				covered[s] = true
+				mark(s)
+			}
+		}
+		mark(n)
+	}
+
+	var dirGroups []DuplicateGroup
+	var members [][]string
+	dirEntry := func(n *dirNode) FileInfo {
+		return FileInfo{Path: n.path, SizeBytes: n.bytes, ModifiedUnix: n.mod, Type: "inode/directory", IsDir: true}
+	}
+
+	// identical directories
+	byHash := map[string][]*dirNode{}
+	var hashes []string
+	for _, n := range all {
+		if n.count < minDirFiles {
+			continue
+		}
+		if _, ok := byHash[n.hash]; !ok {
+			hashes = append(hashes, n.hash)
+		}
+		byHash[n.hash] = append(byHash[n.hash], n)
+	}
+	twin := map[*dirNode]bool{} // second and later copies of an identical set
+	for _, h := range hashes {
+		var set []*dirNode
+		uncovered := false
+		for _, n := range byHash[h] {
+			if isComplete(n) {
+				set = append(set, n)
+				uncovered = uncovered || !covered[n]
+			}
+		}
+		if len(set) < 2 || !uncovered {
+			continue
+		}
+		sort.Slice(set, func(i, j int) bool { return set[i].path < set[j].path })
+		g := DuplicateGroup{GroupID: "dir-" + h[:16], Kind: MatchDirectory, Similarity: 1}
+		var paths []string
+		for i, n := range set {
+			g.Files = append(g.Files, dirEntry(n))
+			paths = append(paths, n.path)
+			cover(n)
+			if i > 0 {
+				twin[n] = true
+			}
+		}
+		dirGroups = append(dirGroups, g)
+		members = append(members, paths)
+	}
+
+	// strict subsets: every file of a directory also exists somewhere below another one
+	index := map[string][]*dirNode{} // token -> directories holding it, each once, by path
+	for _, n := range all {
+		for _, t := range n.files {
+			for a := n; a != nil; a = a.parent {
+				index[t] = append(index[t], a)
+			}
+		}
+	}
+	for t, l := range index {
+		sort.Slice(l, func(i, j int) bool { return l[i].path < l[j].path })
+		out := l[:1]
+		for _, d := range l[1:] {
+			if d != out[len(out)-1] {
+				out = append(out, d)
+			}
+		}
+		index[t] = out
+	}
+	for _, a := range all {
+		if a.count < minDirFiles || covered[a] || twin[a] || !isComplete(a) {
+			continue
+		}
+		ta := dirTokens(a)
+		rare := ""
+		for t := range ta {
+			if rare == "" || len(index[t]) < len(index[rare]) || (len(index[t]) == len(index[rare]) && t < rare) {
+				rare = t
+			}
+		}
+		var best *dirNode
+		for _, b := range index[rare] {
+			if b.count <= a.count || b.hash == a.hash || nested(a.path, b.path) || nested(b.path, a.path) {
+				continue
+			}
+			if best != nil && (b.count > best.count || (b.count == best.count && b.path > best.path)) {
+				continue
+			}
+			if containsTokens(dirTokens(b), ta) && isComplete(b) {
+				best = b
+			}
+		}
+		if best == nil {
+			continue
+		}
+		dirGroups = append(dirGroups, DuplicateGroup{
+			GroupID:    "dir-subset-" + a.hash[:16],
+			Kind:       MatchDirSubset,
+			Files:      []FileInfo{dirEntry(best), dirEntry(a)},
+			Similarity: float64(a.count) / float64(best.count),
+		})
+		members = append(members, []string{best.path, a.path})
+		cover(a)
+	}
+	if len(dirGroups) == 0 {
+		return groups
+	}
+
+	// fold file groups that lie entirely inside one directory group
+	rest := make([]DuplicateGroup, 0, len(groups))
+	for _, g := range groups {
+		placed := false
+		for i := range dirGroups {
+			if groupInside(g, members[i]) {
+				dirGroups[i].Collapsed = append(dirGroups[i].Collapsed, g)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			rest = append(rest, g)
+		}
+	}
+	return append(dirGroups, rest...)
+}
+
+// dirComplete reports whether every entry below n was scanned: files must be known paths,
+// subdirectories complete themselves. The rule file is not content and is allowed.
+func dirComplete(src source, n *dirNode, known map[string]bool, isComplete func(*dirNode) bool, nodes map[string]*dirNode) bool {
+	entries, err := src.readDir(n.path)
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		p := filepath.Join(n.path, e.Name())
+		switch {
+		case e.IsDir():
+			sub, ok := nodes[p]
+			if !ok {
+				// a directory without scanned files is fine only if it is empty all the way down
+				if !emptyTree(src, p) {
+					return false
+				}
+				continue
+			}
+			if !isComplete(sub) {
+				return false
+			}
+		case e.Name() == IgnoreFileName:
+		case !known[p]:
+			return false
+		}
+	}
+	return true
+}
+
+func emptyTree(src source, dir string) bool {
+	empty := true
+	_ = src.walk(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			empty = false
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return empty
+}
+
+// dirTokens returns the multiset of content tokens below n.
+func dirTokens(n *dirNode) map[string]int {
+	if n.tokens != nil {
+		return n.tokens
+	}
+	n.tokens = map[string]int{}
+	for _, t := range n.files {
+		n.tokens[t]++
+	}
+	for _, s := range n.subdirs {
+		for t, c := range dirTokens(s) {
+			n.tokens[t] += c
+		}
+	}
+	return n.tokens
+}
+
+func containsTokens(super, sub map[string]int) bool {
+	for t, c := range sub {
+		if super[t] < c {
+			return false
+		}
+	}
+	return true
+}
+
+// nested reports whether p lies strictly inside dir. The root of an fs.FS is ".", which holds
+// every other relative path.
+func nested(p, dir string) bool {
+	if dir == "." {
+		return p != "." && !filepath.IsAbs(p) && p != ".." && !strings.HasPrefix(p, ".."+string(filepath.Separator))
+	}
+	return strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// groupInside reports whether every path of g lies inside one of dirs.
+func groupInside(g DuplicateGroup, dirs []string) bool {
+	if len(g.Files) == 0 {
+		return false
+	}
+	for _, f := range g.Files {
+		for _, p := range f.Paths() {
+			in := false
+			for _, d := range dirs {
+				if nested(p, d) {
+					in = true
+					break
+				}
+			}
+			if !in {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGroupDirectories(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		exts      []string
+		want      []string
+		collapsed []int // file groups folded into each directory group
+	}{
+		{
+			name:      "identical",
+			files:     map[string]string{"a/x": "1", "a/y": "2", "b/x": "1", "b/y": "2"},
+			want:      []string{"dir a b"},
+			collapsed: []int{2},
+		},
+		{
+			name:      "identical parents only",
+			files:     map[string]string{"a/s/x": "1", "a/s/y": "2", "b/s/x": "1", "b/s/y": "2"},
+			want:      []string{"dir a b"},
+			collapsed: []int{2},
+		},
+		{
+			name:      "subset",
+			files:     map[string]string{"all/x": "1", "all/y": "2", "all/z": "3", "part/x": "1", "part/y": "2"},
+			want:      []string{"dir-subset all part"},
+			collapsed: []int{2},
+		},
+		{
+			name:  "same contents, other names",
+			files: map[string]string{"a/x": "1", "a/y": "2", "b/x": "1", "b/z": "2"},
+			want:  []string{"exact a/x b/x", "exact a/y b/z"},
+		},
+		{
+			name:  "too few files",
+			files: map[string]string{"a/x": "1", "b/x": "1"},
+			want:  []string{"exact a/x b/x"},
+		},
+		{
+			name:  "an entry the scan skipped",
+			files: map[string]string{"a/x.txt": "1", "a/y.txt": "2", "a/z.bin": "3", "b/x.txt": "1", "b/y.txt": "2"},
+			exts:  []string{"txt"},
+			want:  []string{"exact a/x.txt b/x.txt", "exact a/y.txt b/y.txt"},
+		},
+		{
+			name:      "a copy outside",
+			files:     map[string]string{"a/x": "1", "a/y": "2", "b/x": "1", "b/y": "2", "c/x": "1"},
+			want:      []string{"dir a b", "exact a/x b/x c/x"},
+			collapsed: []int{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, _ := scanFS(t, ExactEngine{}, textFS(tt.files), ScanConfig{IncludeExtensions: tt.exts, DetectDirectories: true})
+			if got := describe(groups); !equalStrings(got, tt.want) {
+				t.Fatalf("groups %q, want %q", got, tt.want)
+			}
+			for i, n := range tt.collapsed {
+				if len(groups[i].Collapsed) != n {
+					t.Errorf("%s holds %d file groups, want %d", groups[i].GroupID, len(groups[i].Collapsed), n)
+				}
+			}
+		})
+	}
+}
+
+func TestGroupDirectoriesAllowsEmptySubdirectories(t *testing.T) {
+	fsys := textFS(map[string]string{"a/x": "1", "a/y": "2", "b/x": "1", "b/y": "2"})
+	fsys["a/empty/deeper"] = &fstest.MapFile{Mode: fs.ModeDir}
+	groups, _ := scanFS(t, ExactEngine{}, fsys, ScanConfig{DetectDirectories: true})
+	checkGroups(t, groups, []string{"dir a b"})
+}
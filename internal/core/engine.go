@@ -1,11 +1,183 @@
 package core
 
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
 // ScannerEngine defines the minimal capabilities shared by CLI and GUI.
 // Concrete implementations can optimize for different modes while sharing the interface.
 type ScannerEngine interface {
-	// Scan performs the scan according to the provided configuration and returns duplicate groups.
-	// Engines SHOULD invoke config.OnProgress if not nil to report progress.
-	Scan(config ScanConfig) ([]DuplicateGroup, error)
+	// Scan performs the scan according to the provided configuration and returns duplicate groups
+	// together with a report of the paths that could not be checked.
+	// Engines SHOULD invoke config.OnProgress if not nil to report progress, and config.OnGroup
+	// with every group as soon as it is confirmed.
+	Scan(config ScanConfig) ([]DuplicateGroup, *ScanReport, error)
+	// ScanContext is Scan with cancellation. When ctx is cancelled the engine stops promptly and
+	// returns the groups confirmed so far, marked Incomplete, together with ctx.Err().
+	// Engines SHOULD block on config.Pauser between units of work while it is paused.
+	// The returned report is never nil unless the configuration itself is invalid.
+	ScanContext(ctx context.Context, config ScanConfig) ([]DuplicateGroup, *ScanReport, error)
+}
+
+// DefaultMode is used when ScanConfig.Mode is empty.
+const DefaultMode = "basic"
+
+// EngineInfo describes a scan mode and the engine that serves it.
+type EngineInfo struct {
+	Mode         string
+	Description  string
+	Capabilities EngineCapabilities
+	// Options lists the ScanConfig fields the mode honours beyond the walk and filter settings
+	// shared by all modes; front ends show only these.
+	Options []EngineOption
+	New     func() ScannerEngine
+}
+
+// EngineCapabilities tells front ends what a mode's results look like.
+type EngineCapabilities struct {
+	Exact       bool        // groups hold byte-identical content
+	Similarity  bool        // groups are near-duplicates with a measured Similarity
+	Streaming   bool        // groups reach OnGroup while the scan runs, not only at its end
+	Directories bool        // can report duplicate directories (DetectDirectories)
+	Archives    bool        // can compare archive members (ScanArchives)
+	Classes     []FileClass // content classes compared; empty for all files
+}
+
+// EngineOption describes one mode-specific ScanConfig field.
+type EngineOption struct {
+	Field       string // ScanConfig field name
+	Kind        string // "bool" | "float" | "choice"
+	Description string
+	Default     string
+	Min, Max    float64  // range of a float
+	Choices     []string // values of a choice
+}
+
+// HasOption reports whether the mode honours the ScanConfig field.
+func (e EngineInfo) HasOption(field string) bool {
+	for _, o := range e.Options {
+		if o.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	enginesMu    sync.RWMutex
+	engines      = map[string]EngineInfo{}
+	builtinsOnce sync.Once
+)
+
+// loadBuiltins adds the built-in modes on first use rather than in init: their option schemas
+// list the hashers that other files register in their init functions.
+func loadBuiltins() { builtinsOnce.Do(registerBuiltinEngines) }
+
+func addEngine(e EngineInfo) {
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+	engines[strings.ToLower(e.Mode)] = e
+}
+
+// RegisterEngine adds or replaces the engine of a mode. It is safe to call while scans run.
+func RegisterEngine(e EngineInfo) {
+	loadBuiltins()
+	addEngine(e)
 }
 
+// LookupEngine resolves a mode by name; an empty name selects DefaultMode.
+func LookupEngine(mode string) (EngineInfo, error) {
+	if mode == "" {
+		mode = DefaultMode
+	}
+	loadBuiltins()
+	enginesMu.RLock()
+	e, ok := engines[strings.ToLower(mode)]
+	enginesMu.RUnlock()
+	if !ok {
+		return EngineInfo{}, fmt.Errorf("unknown scan mode %q (available: %s)", mode, strings.Join(EngineModes(), ", "))
+	}
+	return e, nil
+}
 
+// EngineModes lists registered modes, DefaultMode first and the rest in sorted order.
+func EngineModes() []string {
+	loadBuiltins()
+	enginesMu.RLock()
+	modes := make([]string, 0, len(engines))
+	for mode := range engines {
+		modes = append(modes, mode)
+	}
+	enginesMu.RUnlock()
+	sort.Slice(modes, func(i, j int) bool {
+		if (modes[i] == DefaultMode) != (modes[j] == DefaultMode) {
+			return modes[i] == DefaultMode
+		}
+		return modes[i] < modes[j]
+	})
+	return modes
+}
+
+// similarityOption is the SimilarityThreshold schema shared by the similarity modes.
+func similarityOption(def string, desc string) EngineOption {
+	return EngineOption{Field: "SimilarityThreshold", Kind: "float", Description: desc, Default: def, Min: 0, Max: 1}
+}
+
+// clusteringOption is the Clustering schema of the image and video modes.
+func clusteringOption() EngineOption {
+	var choices []string
+	for _, m := range ClusterMethods() {
+		choices = append(choices, string(m))
+	}
+	return EngineOption{Field: "Clustering", Kind: "choice", Description: "how similar pairs form groups", Default: string(ClusterComponents), Choices: choices}
+}
+
+// perceptualOption is the PerceptualHash schema of the image and video modes.
+func perceptualOption() EngineOption {
+	return EngineOption{Field: "PerceptualHash", Kind: "choice", Description: "perceptual hash", Default: DefaultPerceptualHash, Choices: PerceptualHasherNames()}
+}
+
+func registerBuiltinEngines() {
+	addEngine(EngineInfo{
+		Mode:         "basic",
+		Description:  "byte-identical files, staged size/sample/full hashing",
+		Capabilities: EngineCapabilities{Exact: true, Streaming: true, Directories: true, Archives: true},
+		Options: []EngineOption{
+			{Field: "HashAlgorithm", Kind: "choice", Description: "content hash", Default: DefaultHashAlgorithm, Choices: HasherNames()},
+			{Field: "VerifyBytes", Kind: "bool", Description: "confirm groups byte for byte", Default: "false"},
+			{Field: "DetectDirectories", Kind: "bool", Description: "report identical and subset directories", Default: "false"},
+			{Field: "ScanArchives", Kind: "bool", Description: "compare members of zip and tar archives", Default: "false"},
+		},
+		New: func() ScannerEngine { return ExactEngine{} },
+	})
+	addEngine(EngineInfo{
+		Mode:         "image",
+		Description:  "visually similar images by perceptual hash",
+		Capabilities: EngineCapabilities{Similarity: true, Classes: []FileClass{ClassImage}},
+		Options: []EngineOption{
+			similarityOption("0.84", "minimum similarity; 1 - differing hash bits / 64"), perceptualOption(), clusteringOption(),
+			{Field: "RotationInvariant", Kind: "bool", Description: "match rotated and mirrored copies", Default: "false"},
+			{Field: "TrimBorders", Kind: "bool", Description: "crop uniform borders and letterboxing", Default: "false"},
+		},
+		New: func() ScannerEngine { return ImageEngine{} },
+	})
+	addEngine(EngineInfo{
+		Mode:         "video",
+		Description:  "similar videos by a perceptual hash of an early frame (needs ffmpeg)",
+		Capabilities: EngineCapabilities{Similarity: true, Classes: []FileClass{ClassVideo}},
+		Options:      []EngineOption{similarityOption("0.84", "minimum similarity; 1 - differing hash bits / 64"), perceptualOption(), clusteringOption()},
+		New:          func() ScannerEngine { return VideoEngine{} },
+	})
+	addEngine(EngineInfo{
+		Mode:         "text",
+		Description:  "near-duplicate text by MinHash of normalized shingles",
+		Capabilities: EngineCapabilities{Similarity: true, Classes: []FileClass{ClassDocument, ClassCode}},
+		Options:      []EngineOption{similarityOption(strconv.FormatFloat(defaultTextThreshold, 'f', -1, 64), "minimum estimated Jaccard similarity")},
+		New:          func() ScannerEngine { return TextEngine{} },
+	})
+}
@@ -1,49 +1,87 @@
 package core
 
 import (
-    "path/filepath"
-    "strings"
+	"context"
 )
 
-// MediaSimilarity groups images by perceptual hash threshold.
-func MediaSimilarity(files []FileInfo, threshold int) []DuplicateGroup {
-    buckets := map[string][]FileInfo{}
-    hashes := map[string]string{}
-    for _, f := range files {
-        ext := strings.ToLower(filepath.Ext(f.Path))
-        if ext == ".jpg" || ext == ".jpeg" || ext == ".png" {
-            img, err := GenerateImageThumbnail(f.Path, 128)
-            if err != nil { continue }
-            h := PerceptualHash(img)
-            hashes[f.Path] = h
-        }
-    }
-    // simple clustering: compare to first in each bucket key set
-    for _, f := range files {
-        h, ok := hashes[f.Path]
-        if !ok { continue }
-        placed := false
-        for key, list := range buckets {
-            if len(list) == 0 { continue }
-            ref := list[0]
-            dist := HammingDistanceHex(hashes[ref.Path], h)
-            if dist <= threshold {
-                buckets[key] = append(buckets[key], f)
-                placed = true
-                break
-            }
-        }
-        if !placed {
-            buckets[f.Path] = []FileInfo{f}
-        }
-    }
-    // convert to groups (only groups with >=2 files)
-    out := make([]DuplicateGroup, 0, len(buckets))
-    for key, list := range buckets {
-        if len(list) < 2 { continue }
-        out = append(out, DuplicateGroup{ GroupID: key, Files: list })
-    }
-    return out
+// ImageEngine serves image mode: images, recognized by content, are grouped by perceptual hash.
+type ImageEngine struct{}
+
+func (e ImageEngine) Scan(config ScanConfig) ([]DuplicateGroup, *ScanReport, error) {
+	return e.ScanContext(context.Background(), config)
 }
 
+func (ImageEngine) ScanContext(ctx context.Context, config ScanConfig) ([]DuplicateGroup, *ScanReport, error) {
+	ph, err := LookupPerceptualHasher(config.PerceptualHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	method, err := checkClusterMethod(config.Clustering)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := newScanRun(ctx, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.close()
+	r.walk(walkOptions{types: true})
+	r.lim.prog.enter("grouping", totalBytes(r.files))
+	hashing := imageHashing{ph: ph, turns: config.RotationInvariant, trim: config.TrimBorders}
+	groups := mediaSimilarity(r.lim, r.config.HashIndex, r.files, hashing, hammingThreshold(config.SimilarityThreshold), method)
+	return r.done(r.addLinkGroups(groups, 0))
+}
+
+// hammingThreshold converts a 0-1 similarity into the number of the 64 perceptual hash bits
+// allowed to differ; 0 selects the default of 10.
+func hammingThreshold(similarity float64) int {
+	if similarity <= 0 {
+		return 10
+	}
+	bits := int((1.0 - similarity) * 64.0)
+	if bits < 0 {
+		bits = 0
+	}
+	if bits > 64 {
+		bits = 64
+	}
+	return bits
+}
 
+// MediaSimilarity groups images by perceptual hash threshold, using DefaultPerceptualHash and
+// ClusterComponents. Images are recognized by content; files without a Type are sniffed.
+func MediaSimilarity(files []FileInfo, threshold int) []DuplicateGroup {
+	ph, _ := LookupPerceptualHasher(DefaultPerceptualHash)
+	return summarizeGroups(mediaSimilarity(newLimits(context.Background(), ScanConfig{}, nil), nil, files, imageHashing{ph: ph}, threshold, ClusterComponents))
+}
+
+// mediaSimilarity decodes and hashes images at full size on the worker pool, then clusters them.
+// Hashes made the same way and cached in idx are reused. Cancelled scans cluster only the
+// images hashed so far.
+func mediaSimilarity(lim *limits, idx *HashIndex, files []FileInfo, hashing imageHashing, threshold int, method ClusterMethod) []DuplicateGroup {
+	hashes := make([]string, len(files))
+	lim.forEach(len(files), func(i int) {
+		defer lim.prog.advance(files[i].Path, files[i].SizeBytes)
+		mime, _, err := lim.fileType(idx, files[i])
+		if err != nil {
+			lim.rep.addIO(files[i].Path, "media", err)
+			return
+		}
+		if decodableImages[mime] {
+			if h, ok := idx.perceptualHash(files[i], hashing.key()); ok {
+				hashes[i] = h
+				return
+			}
+			lim.acquireIO(files[i].Device)
+			img, upright, err := decodeOrientedImage(lim.src, files[i].Path)
+			lim.releaseIO(files[i].Device)
+			if err != nil {
+				lim.rep.addMedia(files[i].Path, err, false)
+				return
+			}
+			hashes[i] = hashing.hash(img, upright)
+			idx.putPerceptualHash(files[i], hashing.key(), hashes[i])
+		}
+	})
+	return clusterByHash(lim, files, hashes, hashing.ph.Name, threshold, method)
+}
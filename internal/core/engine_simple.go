@@ -1,176 +1,609 @@
 package core
 
 import (
-	"crypto/sha1"
-	"encoding/hex"
-	"fmt"
-	"io"
+	"context"
 	"os"
 	"path/filepath"
-	"strings"
+	"sort"
 	"sync"
 )
 
-// SimpleScanner is a minimal, synchronous implementation to get things working end-to-end.
-// It walks include paths, applies exclude patterns, computes a fast hash (sha1) for small files,
-// and groups files by identical hash. This is a placeholder to be replaced by an optimized engine.
+// SimpleScanner runs whichever registered engine serves ScanConfig.Mode, so callers that do not
+// care about modes need a single scanner. See RegisterEngine.
 type SimpleScanner struct{}
 
 func NewSimpleScanner() *SimpleScanner { return &SimpleScanner{} }
 
-func (s *SimpleScanner) Scan(config ScanConfig) ([]DuplicateGroup, error) {
-	// collect all files
-	var mu sync.Mutex
-	files := make([]FileInfo, 0, 1024)
-	var count int
+func (s *SimpleScanner) Scan(config ScanConfig) ([]DuplicateGroup, *ScanReport, error) {
+	return s.ScanContext(context.Background(), config)
+}
 
-	report := func(stage string) {
-		if config.OnProgress != nil {
-			config.OnProgress(Progress{Stage: stage, FilesScanned: count})
-		}
+func (s *SimpleScanner) ScanContext(ctx context.Context, config ScanConfig) ([]DuplicateGroup, *ScanReport, error) {
+	info, err := LookupEngine(config.Mode)
+	if err != nil {
+		return nil, nil, err
 	}
+	return info.New().ScanContext(ctx, config)
+}
 
-	shouldExclude := func(path string) bool {
-		for _, pat := range config.ExcludePatterns {
-			if pat == "" {
-				continue
-			}
-			matched, _ := filepath.Match(pat, filepath.Base(path))
-			if matched {
-				return true
-			}
-			// allow glob on full path too
-			matched, _ = filepath.Match(pat, path)
-			if matched {
-				return true
+// ExactEngine serves basic mode. It narrows candidates in stages: files are grouped by size,
+// size collisions get a head/tail sample hash, and only files whose samples collide are hashed
+// in full. It also reports hardlinks, symlinks, archive members and duplicate directories.
+type ExactEngine struct{}
+
+func (e ExactEngine) Scan(config ScanConfig) ([]DuplicateGroup, *ScanReport, error) {
+	return e.ScanContext(context.Background(), config)
+}
+
+func (ExactEngine) ScanContext(ctx context.Context, config ScanConfig) ([]DuplicateGroup, *ScanReport, error) {
+	r, err := newScanRun(ctx, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.close()
+	r.walk(walkOptions{archives: config.ScanArchives})
+	groups := groupExact(r.files, r.alg, r.lim, r.config.HashIndex, config.VerifyBytes || !r.alg.CollisionSafe, r.emit)
+	groups = r.addLinkGroups(groups, len(groups))
+	if config.DetectDirectories && ctx.Err() == nil {
+		r.lim.prog.enter("grouping", 0)
+		groups = groupDirectories(r.lim.src, r.roots, r.files, groups)
+		// file groups folded into a directory group were streamed before it
+		for _, g := range groups {
+			if g.Kind == MatchDirectory || g.Kind == MatchDirSubset {
+				r.emit(g)
 			}
 		}
-		return false
 	}
+	return r.done(groups)
+}
+
+// scanRun is one scan in progress: the walk, worker pool, report and progress shared by the
+// mode engines. It walks include paths in parallel, applies gitignore-style exclude/include
+// rules and .hasteignore files (pruning excluded directories) and the metadata filters. Reads
+// and hashing run on a bounded worker pool sized by ScanConfig.Concurrency, IOConcurrency,
+// HashConcurrency and PerDeviceIO. The OS filesystem is scanned unless ScanConfig.FS supplies
+// another one.
+type scanRun struct {
+	ctx    context.Context
+	config ScanConfig
+	alg    Hasher
+	rep    *ScanReport
+	rules  *walkRules
+	filter *fileFilter
+	lim    *limits
+
+	roots    []string
+	files    []FileInfo
+	symlinks []FileInfo // SymlinksReport only; never hashed
+	dirs     []string   // directories visited, with walkOptions.dirs
 
-	report("walking")
-	walker := func(root string) error {
-		return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+	emitMu       sync.Mutex
+	stopProgress func()
+	stopFlush    func()
+}
+
+// walkOptions are the parts of the walk an engine decides on.
+type walkOptions struct {
+	archives bool // expand zip/tar members into virtual files
+	types    bool // detect the content type of every file
+	dirs     bool // record the directories visited in scanRun.dirs
+}
+
+// newScanRun validates config and starts progress reporting; close must be called when done.
+func newScanRun(ctx context.Context, config ScanConfig) (*scanRun, error) {
+	alg, err := LookupHasher(config.HashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	rep := &ScanReport{}
+	rules, err := newWalkRules(config, rep)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := newFileFilter(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.SymlinkPolicy, err = checkSymlinkPolicy(config.SymlinkPolicy); err != nil {
+		return nil, err
+	}
+	lim := newLimits(ctx, config, rep)
+	lim.src.zips = newZipCache() // closed with the run
+	if !lim.src.isOS() {
+		// fs.FS has no portable way to read links, and its paths mean nothing to the on-disk index
+		config.SymlinkPolicy = SymlinksIgnore
+		config.HashIndex = nil
+		if len(config.IncludePaths) == 0 {
+			config.IncludePaths = []string{"."}
+		}
+	}
+	r := &scanRun{ctx: ctx, config: config, alg: alg, rep: rep, rules: rules, filter: filter, lim: lim}
+	// progress is reported on stage changes and, in between, at a steady pace
+	r.stopProgress = lim.prog.run()
+	// hashes reach the log during the scan too, not only when it ends
+	r.stopFlush = config.HashIndex.flushEvery(hashIndexFlushInterval, func(err error) {
+		rep.addIO(config.HashIndex.Path(), "cache", err)
+	})
+	return r, nil
+}
+
+func (r *scanRun) close() {
+	r.stopProgress()
+	r.stopFlush()
+	r.lim.src.zips.close()
+}
+
+// emit streams a confirmed group to config.OnGroup, one call at a time.
+func (r *scanRun) emit(g DuplicateGroup) {
+	r.lim.prog.groups.Add(1)
+	if r.config.OnGroup == nil {
+		return
+	}
+	g.summarize()
+	r.emitMu.Lock()
+	defer r.emitMu.Unlock()
+	r.config.OnGroup(g)
+}
+
+// walk collects the files to compare into r.files, sorted by path with hardlinks collapsed.
+func (r *scanRun) walk(opts walkOptions) {
+	config, lim, rep, rules, filter := r.config, r.lim, r.rep, r.rules, r.filter
+	var mu sync.Mutex
+	files := make([]FileInfo, 0, 1024)
+	var symlinks []FileInfo
+	var archives []FileInfo // expanded into their members after the walk
+	var dirs []string
+
+	lim.prog.enter("walking", 0)
+	type dirID struct{ dev, ino uint64 }
+	visited := map[dirID]bool{}
+	// enterDir reports whether a directory is seen for the first time; following symlinks can
+	// otherwise reach the same directory again or loop forever.
+	enterDir := func(path string, info os.FileInfo) bool {
+		dev, ino := fileIdentity(path, info)
+		if ino == 0 {
+			return true
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if visited[dirID{dev, ino}] {
+			return false
+		}
+		visited[dirID{dev, ino}] = true
+		return true
+	}
+	addFile := func(fi FileInfo) {
+		mu.Lock()
+		files = append(files, fi)
+		mu.Unlock()
+		lim.prog.found(fi.Path, fi.SizeBytes)
+	}
+	var walker func(root string) error
+	walker = func(root string) error {
+		return lim.src.walk(root, func(path string, d os.DirEntry, err error) error {
+			if lim.checkpoint() != nil {
+				return filepath.SkipAll
+			}
 			if err != nil {
+				rep.addIO(path, "walking", err)
 				return nil // skip unreadable entries
 			}
 			if d.IsDir() {
+				if rules.excluded(root, path, true) {
+					return filepath.SkipDir
+				}
+				info, err := d.Info()
+				if err == nil && path != root && filter.skipDir(d.Name(), info) {
+					return filepath.SkipDir
+				}
+				if err == nil && config.SymlinkPolicy == SymlinksFollow && !enterDir(path, info) {
+					return filepath.SkipDir
+				}
+				rules.enter(path)
+				if opts.dirs {
+					mu.Lock()
+					dirs = append(dirs, path)
+					mu.Unlock()
+				}
 				return nil
 			}
-			if shouldExclude(path) {
+			if d.Name() == IgnoreFileName {
+				return nil
+			}
+			if config.SymlinkPolicy == SymlinksFollow && d.Type()&os.ModeSymlink != 0 {
+				// a linked directory is pruned by directory rules, a linked file by file rules
+				if target, err := os.Stat(path); err == nil && target.IsDir() && rules.excluded(root, path, true) {
+					return nil
+				}
+			}
+			if rules.excluded(root, path, false) {
 				return nil
 			}
 			info, err := d.Info()
 			if err != nil {
+				rep.addIO(path, "walking", err)
 				return nil
 			}
-			// size filters
-			if config.MinSizeBytes > 0 && info.Size() < config.MinSizeBytes {
-				return nil
+			link := ""
+			if info.Mode()&os.ModeSymlink != 0 {
+				switch config.SymlinkPolicy {
+				case SymlinksFollow:
+					target, err := os.Stat(path)
+					if err != nil {
+						rep.addIO(path, "walking", err)
+						return nil
+					}
+					if target.IsDir() {
+						// walk the resolved directory; WalkDir itself never follows links, and the
+						// walk of real skips it at once if it was entered before
+						real, err := filepath.EvalSymlinks(path)
+						if err != nil {
+							rep.addIO(path, "walking", err)
+							return nil
+						}
+						_ = walker(real)
+						return nil
+					}
+					// the link takes the identity of its target, so it collapses with it
+					info = target
+				case SymlinksReport:
+					if dest, err := os.Readlink(path); err == nil {
+						link = dest
+					} else {
+						rep.addIO(path, "walking", err)
+						return nil
+					}
+				default:
+					return nil
+				}
 			}
-			if config.MaxSizeBytes > 0 && info.Size() > config.MaxSizeBytes {
-				return nil
+			if link == "" && !info.Mode().IsRegular() {
+				return nil // devices, pipes and sockets are not content
+			}
+			var dev, ino uint64
+			if lim.src.isOS() {
+				dev, ino = fileIdentity(path, info)
 			}
-			hash := quickHash(path, info.Size())
-			mu.Lock()
-			files = append(files, FileInfo{
+			fi := FileInfo{
 				Path:         path,
 				SizeBytes:    info.Size(),
 				ModifiedUnix: info.ModTime().Unix(),
-				Hash:         hash,
-				Type:         strings.ToLower(filepath.Ext(path)),
-			})
-			count++
-			mu.Unlock()
-			if count%200 == 0 {
-				report("walking")
+				Device:       dev,
+				Inode:        ino,
+				modNano:      info.ModTime().UnixNano(),
+			}
+			if link == "" && opts.archives && isArchiveName(d.Name()) {
+				// members are filtered on their own, whatever the filters say about the archive
+				mu.Lock()
+				archives = append(archives, fi)
+				mu.Unlock()
+			}
+			// size, time, extension and attribute filters
+			if link == "" && !filter.keep(d.Name(), info) {
+				return nil
+			}
+			if link != "" {
+				mu.Lock()
+				symlinks = append(symlinks, FileInfo{Path: path, ModifiedUnix: fi.ModifiedUnix, LinkTarget: link, Type: "symlink"})
+				mu.Unlock()
+				return nil
 			}
+			addFile(fi)
 			return nil
 		})
 	}
 
+	roots := make([]string, 0, len(config.IncludePaths))
 	for _, root := range config.IncludePaths {
 		if root == "" {
 			continue
 		}
-		_ = walker(root)
+		if !lim.src.isOS() {
+			root = filepath.FromSlash(lim.src.fsName(root))
+		} else if info, err := os.Lstat(root); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			// include roots are explicit, so a linked root is always resolved whatever the policy
+			if real, err := filepath.EvalSymlinks(root); err == nil {
+				root = real
+			}
+		}
+		roots = append(roots, root)
+	}
+	lim.forEach(len(roots), func(i int) { _ = walker(roots[i]) })
+	// parallel walks finish in any order; keep results deterministic
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	// hardlinks are one copy on disk: group each inode once
+	files = collapseHardlinks(files)
+	if len(archives) > 0 {
+		lim.prog.enter("archive", totalBytes(archives))
+		sort.Slice(archives, func(i, j int) bool { return archives[i].Path < archives[j].Path })
+		files = append(files, expandArchives(lim, archives, filter)...)
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	}
+	// content types need a read of every file, so they are detected after the cheap filters
+	// and only when something selects by type; otherwise they are sniffed from data read anyway
+	if len(filter.classes) > 0 || opts.types {
+		detectTypes(lim, config.HashIndex, files)
+	}
+	files = filterClasses(files, filter.classes)
+
+	sort.Strings(dirs)
+	r.roots, r.files, r.symlinks, r.dirs = roots, files, symlinks, dirs
+}
+
+// addLinkGroups appends the hardlink and symlink groups to groups and streams every group from
+// index streamed on; engines that stream as they go pass len(groups).
+func (r *scanRun) addLinkGroups(groups []DuplicateGroup, streamed int) []DuplicateGroup {
+	groups = append(groups, linkedGroups(r.files, groups)...)
+	groups = append(groups, symlinkGroups(r.symlinks)...)
+	for _, g := range groups[streamed:] {
+		r.emit(g)
+	}
+	return groups
+}
+
+// done flushes the hash index and returns the result of the scan, with the figures of every
+// group filled in and groups marked Incomplete when it was cancelled.
+func (r *scanRun) done(groups []DuplicateGroup) ([]DuplicateGroup, *ScanReport, error) {
+	summarizeGroups(groups)
+	if err := r.config.HashIndex.Flush(); err != nil {
+		r.rep.addIO(r.config.HashIndex.Path(), "cache", err)
+	}
+	r.lim.prog.groups.Store(int64(len(groups)))
+	if err := r.ctx.Err(); err != nil {
+		for i := range groups {
+			groups[i].Incomplete = true
+		}
+		r.lim.prog.enter("cancelled", 0)
+		return groups, r.rep, err
+	}
+	r.lim.prog.enter("done", 0)
+	return groups, r.rep, nil
+}
+
+// groupExact finds byte-identical files with a staged pipeline so that files with a unique
+// size are never opened and only sample collisions are read in full.
+// Files whose samples collide but whose full hashes differ are reported as MatchPrefix
+// candidates with one representative per distinct content. With verify set, exact groups
+// are additionally confirmed byte for byte. Hashes found in idx for unchanged files are reused.
+// Each group is passed to emit as soon as it is confirmed; the result lists them in a stable order.
+func groupExact(files []FileInfo, alg Hasher, lim *limits, idx *HashIndex, verify bool, emit func(DuplicateGroup)) []DuplicateGroup {
+	bySize := map[int64][]FileInfo{}
+	for _, f := range files {
+		bySize[f.SizeBytes] = append(bySize[f.SizeBytes], f)
+	}
+	sizes := make([]int64, 0, len(bySize))
+	for size, list := range bySize {
+		if len(list) >= 2 {
+			sizes = append(sizes, size)
+		}
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] > sizes[j] })
+
+	// stage 2: head/tail sample for every size collision
+	var candidates []FileInfo
+	for _, size := range sizes {
+		candidates = append(candidates, bySize[size]...)
+	}
+	samples := make([]string, len(candidates))
+	var work int64
+	for _, f := range candidates {
+		work += sampleCost(f)
+	}
+	lim.prog.enter("hashing", work)
+	lim.hashTarMembers(alg, idx, candidates)
+	lim.forEach(len(candidates), func(i int) {
+		f := &candidates[i]
+		defer lim.prog.advance(f.Path, sampleCost(*f)) // before sampling changes what it costs
+		if h, ok := idx.contentHash(*f, alg.Name, false); ok {
+			samples[i] = h
+			if mime, class, ok := idx.fileType(*f); ok {
+				f.Type, f.Class = mime, class
+			}
+		} else if h, err := lim.sampleFile(alg, f); err == nil {
+			samples[i] = h
+			idx.putContentHash(*f, alg.Name, false, h)
+			idx.putFileType(*f, f.Type, f.Class)
+			if f.SizeBytes <= 2*sampleBytes {
+				idx.putContentHash(*f, alg.Name, true, h)
+			}
+		} else {
+			lim.rep.addIO(f.Path, "hashing", err)
+		}
+	})
+
+	// sample collisions, in candidate order; small files were hashed in full by the sample stage
+	type sampleSet struct {
+		id    string
+		files []FileInfo
+	}
+	var sets []*sampleSet
+	bySample := map[string]*sampleSet{}
+	for i, f := range candidates {
+		sh := samples[i]
+		if sh == "" {
+			continue
+		}
+		key := itoa64(f.SizeBytes) + ":" + sh
+		set, ok := bySample[key]
+		if !ok {
+			set = &sampleSet{id: sh}
+			bySample[key] = set
+			sets = append(sets, set)
+		}
+		set.files = append(set.files, f)
 	}
 
-	report("grouping")
-	// grouping strategy by mode
+	// stage 3: full hash only for the survivors. A set is grouped, verified and emitted as soon
+	// as its last survivor is hashed, so large scans report groups long before they finish.
+	type survivor struct {
+		f   *FileInfo
+		set int
+	}
+	var survivors []survivor
+	left := make([]int, len(sets)) // survivors of each set still to hash
+	for si, set := range sets {
+		if len(set.files) < 2 {
+			continue
+		}
+		for i := range set.files {
+			f := &set.files[i]
+			if f.SizeBytes <= 2*sampleBytes {
+				f.Hash = set.id
+				f.HashAlgorithm = alg.Name
+				continue
+			}
+			if f.Archive != "" && f.Hash != "" {
+				continue // archive members are hashed in full while their sample is streamed
+			}
+			survivors = append(survivors, survivor{f, si})
+			left[si]++
+			lim.prog.grow(f.SizeBytes)
+		}
+	}
+	prefixes := make([]*DuplicateGroup, len(sets))
+	exacts := make([][]DuplicateGroup, len(sets))
+	finish := func(si int) {
+		prefixes[si], exacts[si] = groupSampleSet(lim, sets[si].id, sets[si].files, verify)
+		if prefixes[si] != nil {
+			emit(*prefixes[si])
+		}
+		for _, g := range exacts[si] {
+			emit(g)
+		}
+	}
+	var ready []int // sets without survivors are complete already
+	for si, set := range sets {
+		if len(set.files) >= 2 && left[si] == 0 {
+			ready = append(ready, si)
+		}
+	}
+	lim.forEach(len(ready), func(i int) { finish(ready[i]) })
+	var mu sync.Mutex
+	lim.forEach(len(survivors), func(i int) {
+		f := survivors[i].f
+		if h, ok := idx.contentHash(*f, alg.Name, true); ok {
+			f.Hash = h
+			f.HashAlgorithm = alg.Name
+			lim.prog.advance(f.Path, f.SizeBytes) // hashFile counts the bytes it reads itself
+		} else if h, err := lim.hashFile(alg, *f); err == nil {
+			f.Hash = h
+			f.HashAlgorithm = alg.Name
+			idx.putContentHash(*f, alg.Name, true, h)
+		} else {
+			lim.rep.addIO(f.Path, "hashing", err)
+		}
+		mu.Lock()
+		left[survivors[i].set]--
+		done := left[survivors[i].set] == 0
+		mu.Unlock()
+		if done {
+			finish(survivors[i].set)
+		}
+	})
+
+	// prefix candidates first, then exact groups, each in set order, whatever order sets finished in
+	lim.prog.enter("grouping", 0)
 	var groups []DuplicateGroup
-	switch config.Mode {
-	case "image":
-		threshold := 10
-		if config.SimilarityThreshold > 0 {
-			bits := int((1.0 - config.SimilarityThreshold) * 64.0)
-			if bits < 0 {
-				bits = 0
-			}
-			if bits > 64 {
-				bits = 64
-			}
-			threshold = bits
-		}
-		groups = MediaSimilarity(files, threshold)
-	case "video":
-		threshold := 10
-		if config.SimilarityThreshold > 0 {
-			bits := int((1.0 - config.SimilarityThreshold) * 64.0)
-			if bits < 0 {
-				bits = 0
-			}
-			if bits > 64 {
-				bits = 64
-			}
-			threshold = bits
-		}
-		// filter to likely video extensions
-		videoFiles := make([]FileInfo, 0, len(files))
-		for _, f := range files {
-			ext := strings.ToLower(f.Type)
-			if ext == ".mp4" || ext == ".mov" || ext == ".avi" || ext == ".mkv" || ext == ".wmv" {
-				videoFiles = append(videoFiles, f)
-			}
-		}
-		groups = VideoSimilarity(videoFiles, threshold)
-	default:
-		byHash := map[string][]FileInfo{}
-		for _, f := range files {
-			byHash[f.Hash] = append(byHash[f.Hash], f)
-		}
-		for h, fs := range byHash {
-			if len(fs) < 2 {
+	for _, g := range prefixes {
+		if g != nil {
+			groups = append(groups, *g)
+		}
+	}
+	for _, gs := range exacts {
+		groups = append(groups, gs...)
+	}
+	return groups
+}
+
+// groupSampleSet splits files whose samples collide by full hash. Full hashes with several files
+// become exact groups, confirmed byte for byte with verify; distinct full hashes within the set
+// make a MatchPrefix group with one representative each. Files without a full hash are dropped.
+func groupSampleSet(lim *limits, id string, files []FileInfo, verify bool) (*DuplicateGroup, []DuplicateGroup) {
+	byFull := map[string][]FileInfo{}
+	var fulls []string
+	for _, f := range files {
+		if f.Hash == "" {
+			continue // unreadable during full hash
+		}
+		if _, ok := byFull[f.Hash]; !ok {
+			fulls = append(fulls, f.Hash)
+		}
+		byFull[f.Hash] = append(byFull[f.Hash], f)
+	}
+	var prefix *DuplicateGroup
+	if len(fulls) >= 2 {
+		reps := make([]FileInfo, 0, len(fulls))
+		for _, fh := range fulls {
+			reps = append(reps, byFull[fh][0])
+		}
+		prefix = &DuplicateGroup{GroupID: "prefix-" + id, Kind: MatchPrefix, Files: reps}
+	}
+	var exact []DuplicateGroup
+	for _, fh := range fulls {
+		if len(byFull[fh]) < 2 {
+			continue
+		}
+		if !verify {
+			exact = append(exact, DuplicateGroup{GroupID: fh, Kind: MatchExact, Files: byFull[fh]})
+			continue
+		}
+		for j, part := range splitByContent(lim, byFull[fh]) {
+			if len(part) < 2 {
 				continue
 			}
-			groups = append(groups, DuplicateGroup{GroupID: h, Files: fs})
+			gid := fh
+			if j > 0 {
+				gid = fh + "-" + itoa(j)
+			}
+			exact = append(exact, DuplicateGroup{GroupID: gid, Kind: MatchExact, Files: part})
 		}
 	}
+	return prefix, exact
+}
 
-	report("done")
-	return groups, nil
+// sampleCost is the number of bytes the sample stage reads for f: the head and tail, all of a
+// small file, or the whole of an archive member that was not streamed already.
+func sampleCost(f FileInfo) int64 {
+	switch {
+	case f.Archive != "" && f.sample != "":
+		return 0
+	case f.Archive != "" || f.SizeBytes <= 2*sampleBytes:
+		return f.SizeBytes
+	}
+	return 2 * sampleBytes
 }
 
-// quickHash computes a modest hash for small portion of the file for speed.
-// For now, hash the whole file up to 1MB to keep it simple.
-func quickHash(path string, size int64) string {
-	f, err := os.Open(path)
-	if err != nil {
-		return fallbackHash(path, size)
-	}
-	defer f.Close()
-	const limit int64 = 1 << 20
-	h := sha1.New()
-	_, _ = io.CopyN(h, f, limit)
-	sum := h.Sum(nil)
-	return hex.EncodeToString(sum)
-}
-
-func fallbackHash(path string, size int64) string {
-	// deterministic fallback using metadata
-	h := sha1.New()
-	_, _ = io.WriteString(h, path)
-	_, _ = io.WriteString(h, "|")
-	_, _ = fmt.Fprintf(h, "%d", size)
-	return hex.EncodeToString(h.Sum(nil))
+// totalBytes sums the sizes of files.
+func totalBytes(files []FileInfo) int64 {
+	var n int64
+	for _, f := range files {
+		n += f.SizeBytes
+	}
+	return n
+}
+
+// splitByContent partitions files into sets of byte-identical content.
+// Files that cannot be read are dropped.
+func splitByContent(lim *limits, files []FileInfo) [][]FileInfo {
+	var parts [][]FileInfo
+	for _, f := range files {
+		placed := false
+		for i, part := range parts {
+			same, err := lim.sameContent(part[0], f)
+			if err != nil {
+				lim.rep.addIO(f.Path, "verifying", err)
+				placed = true
+				break
+			}
+			if same {
+				parts[i] = append(parts[i], f)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			parts = append(parts, []FileInfo{f})
+		}
+	}
+	return parts
 }
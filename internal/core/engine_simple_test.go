@@ -0,0 +1,69 @@
+package core
+
+import "testing"
+
+func TestGroupExact(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		alg   string
+		want  []string
+	}{
+		{
+			name:  "unique sizes",
+			files: map[string]string{"a": "1", "b": "22", "c": "333"},
+		},
+		{
+			name:  "same size, other content",
+			files: map[string]string{"a": "abc", "b": "abd"},
+		},
+		{
+			name:  "copies",
+			files: map[string]string{"a": "same", "b": "same", "c/d": "same", "e": "else"},
+			want:  []string{"exact a b c/d"},
+		},
+		{
+			name:  "two sets of one size",
+			files: map[string]string{"a": "one", "b": "two", "c": "one", "d": "two"},
+			want:  []string{"exact a c", "exact b d"},
+		},
+		{
+			name:  "equal samples, other middle",
+			files: map[string]string{"a": large('1'), "b": large('2'), "c": large('1')},
+			want:  []string{"prefix a b", "exact a c"},
+		},
+		{
+			name:  "unsafe hash confirmed byte for byte",
+			files: map[string]string{"a": large('1'), "b": large('1'), "c": "small", "d": "small"},
+			alg:   "xxh64",
+			want:  []string{"exact a b", "exact c d"},
+		},
+		{
+			name:  "empty files",
+			files: map[string]string{"a": "", "b": ""},
+			want:  []string{"exact a b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := walkFS(t, textFS(tt.files), ScanConfig{}, walkOptions{})
+			alg, err := LookupHasher(tt.alg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			emitted := 0
+			groups := groupExact(r.files, alg, r.lim, nil, !alg.CollisionSafe, func(DuplicateGroup) { emitted++ })
+			checkGroups(t, groups, tt.want)
+			if emitted != len(groups) {
+				t.Errorf("emitted %d groups, returned %d", emitted, len(groups))
+			}
+			for _, g := range groups {
+				for _, f := range g.Files {
+					if f.Hash == "" || f.HashAlgorithm != alg.Name {
+						t.Errorf("%s: hash %q by %q", f.Path, f.Hash, f.HashAlgorithm)
+					}
+				}
+			}
+		})
+	}
+}
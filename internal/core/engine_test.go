@@ -0,0 +1,46 @@
+package core
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"sync"
+	"testing"
+)
+
+// TestRegistriesAreSafeForConcurrentUse registers while others look up; run it with -race.
+func TestRegistriesAreSafeForConcurrentUse(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("test-registry-%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			RegisterEngine(EngineInfo{Mode: name, New: func() ScannerEngine { return ExactEngine{} }})
+			RegisterHasher(Hasher{Name: name, New: sha256.New})
+			RegisterPerceptualHasher(PerceptualHasher{Name: name, Hash: func(image.Image) uint64 { return 0 }})
+		}()
+		go func() {
+			defer wg.Done()
+			EngineModes()
+			LookupEngine("")
+			LookupHasher("")
+			HasherNames()
+			LookupPerceptualHasher("")
+			PerceptualHasherNames()
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("test-registry-%d", i)
+		if _, err := LookupEngine(name); err != nil {
+			t.Error(err)
+		}
+		if _, err := LookupHasher(name); err != nil {
+			t.Error(err)
+		}
+		if _, err := LookupPerceptualHasher(name); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+const (
+	// maxTextBytes skips files too large to be edited text documents.
+	maxTextBytes = 16 << 20
+	// textShingleSize is the number of tokens per shingle.
+	textShingleSize = 4
+	// minHashBands x minHashRows = signature length. With 4 rows per band, pairs above
+	// ~0.6 Jaccard similarity become candidates with high probability.
+	minHashBands = 32
+	minHashRows  = 4
+	minHashSize  = minHashBands * minHashRows
+	// defaultTextThreshold is used when ScanConfig.SimilarityThreshold is 0.
+	defaultTextThreshold = 0.8
+)
+
+// errTextTooLarge is reported for text files over maxTextBytes, which text mode does not read.
+var errTextTooLarge = fmt.Errorf("larger than the %d MiB text mode reads", maxTextBytes>>20)
+
+// TextEngine serves text mode: near-duplicate text files are grouped by MinHash similarity.
+type TextEngine struct{}
+
+func (e TextEngine) Scan(config ScanConfig) ([]DuplicateGroup, *ScanReport, error) {
+	return e.ScanContext(context.Background(), config)
+}
+
+func (TextEngine) ScanContext(ctx context.Context, config ScanConfig) ([]DuplicateGroup, *ScanReport, error) {
+	r, err := newScanRun(ctx, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.close()
+	// only files sniffed as text are read in full
+	r.walk(walkOptions{types: true})
+	var texts []FileInfo
+	for _, f := range r.files {
+		if isText(f) {
+			texts = append(texts, f)
+		}
+	}
+	r.lim.prog.enter("grouping", totalBytes(texts))
+	groups := textSimilarity(r.lim, texts, config.SimilarityThreshold)
+	return r.done(r.addLinkGroups(groups, 0))
+}
+
+// isText reports whether f was sniffed as text; PDFs and office files of the same classes are not.
+func isText(f FileInfo) bool {
+	return strings.HasPrefix(f.Type, "text/")
+}
+
+// TextSimilarity groups near-duplicate text files whose estimated Jaccard similarity of token
+// shingles is at least threshold (0-1).
+func TextSimilarity(files []FileInfo, threshold float64) []DuplicateGroup {
+	return summarizeGroups(textSimilarity(newLimits(context.Background(), ScanConfig{}, nil), files, threshold))
+}
+
+// textSimilarity normalizes each text file, builds a MinHash signature of its shingles, finds
+// candidate pairs with LSH banding and joins pairs at or above threshold into groups.
+func textSimilarity(lim *limits, files []FileInfo, threshold float64) []DuplicateGroup {
+	if threshold <= 0 {
+		threshold = defaultTextThreshold
+	}
+	sigs := make([][]uint64, len(files))
+	lim.forEach(len(files), func(i int) {
+		f := files[i]
+		defer lim.prog.advance(f.Path, f.SizeBytes)
+		if f.Type != "" && !isText(f) {
+			return
+		}
+		if f.SizeBytes > maxTextBytes {
+			lim.rep.add(f.Path, "text", ErrorTooLarge, errTextTooLarge)
+			return
+		}
+		lim.acquireIO(f.Device)
+		data, err := readAllLimited(lim.src, f.Path, maxTextBytes)
+		lim.releaseIO(f.Device)
+		if err != nil {
+			lim.rep.addIO(f.Path, "text", err)
+			return
+		}
+		if f.Type == "" {
+			files[i].Type, files[i].Class = sniffType(sniffHead(data), f.Path)
+		}
+		text, ok := NormalizeText(data)
+		if !ok || text == "" {
+			return
+		}
+		sigs[i] = minHashSignature(shingles(text))
+	})
+
+	// LSH: files sharing any band bucket are candidates
+	uf := newUnionFind(len(files))
+	edgeSim := map[[2]int]float64{}
+	for b := 0; b < minHashBands; b++ {
+		buckets := map[uint64][]int{}
+		for i, sig := range sigs {
+			if sig == nil {
+				continue
+			}
+			h := fnv.New64a()
+			var buf [8]byte
+			for _, v := range sig[b*minHashRows : (b+1)*minHashRows] {
+				binary.LittleEndian.PutUint64(buf[:], v)
+				h.Write(buf[:])
+			}
+			key := h.Sum64()
+			buckets[key] = append(buckets[key], i)
+		}
+		for _, members := range buckets {
+			for x := 0; x < len(members); x++ {
+				for y := x + 1; y < len(members); y++ {
+					pair := [2]int{members[x], members[y]}
+					if _, seen := edgeSim[pair]; seen {
+						continue
+					}
+					sim := signatureSimilarity(sigs[pair[0]], sigs[pair[1]])
+					edgeSim[pair] = sim
+					if sim >= threshold {
+						uf.union(pair[0], pair[1])
+					}
+				}
+			}
+		}
+	}
+
+	// the weakest measured link that holds each group together is its score
+	score := map[int]float64{}
+	for pair, sim := range edgeSim {
+		if sim < threshold {
+			continue
+		}
+		r := uf.find(pair[0])
+		if cur, ok := score[r]; !ok || sim < cur {
+			score[r] = sim
+		}
+	}
+	components := map[int][]int{}
+	var roots []int
+	for i, sig := range sigs {
+		if sig == nil {
+			continue
+		}
+		r := uf.find(i)
+		if _, ok := components[r]; !ok {
+			roots = append(roots, r)
+		}
+		components[r] = append(components[r], i)
+	}
+	var groups []DuplicateGroup
+	for _, r := range roots {
+		members := components[r]
+		if len(members) < 2 {
+			continue
+		}
+		list := make([]FileInfo, 0, len(members))
+		for _, i := range members {
+			list = append(list, files[i])
+		}
+		groups = append(groups, DuplicateGroup{GroupID: list[0].Path, Kind: MatchText, Files: list, Similarity: score[r]})
+	}
+	return groups
+}
+
+// NormalizeText decodes UTF-8, UTF-16 (with BOM or detectable by NUL layout) or GBK content,
+// unifies line endings and collapses whitespace runs, so that files differing only in encoding
+// or formatting compare equal. It reports false for content that does not look like text.
+func NormalizeText(data []byte) (string, bool) {
+	text, ok := decodeText(data)
+	if !ok {
+		return "", false
+	}
+	var b strings.Builder
+	b.Grow(len(text))
+	space := false
+	for _, r := range text {
+		if r == 0 {
+			return "", false
+		}
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String(), true
+}
+
+func decodeText(data []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:]), utf8.Valid(data[3:])
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], binary.LittleEndian), true
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], binary.BigEndian), true
+	}
+	if order, ok := sniffUTF16(data); ok {
+		return decodeUTF16(data, order), true
+	}
+	if utf8.Valid(data) {
+		return string(data), true
+	}
+	out, err := simplifiedchinese.GBK.NewDecoder().Bytes(data)
+	if err != nil || bytes.ContainsRune(out, utf8.RuneError) {
+		return "", false
+	}
+	return string(out), true
+}
+
+// sniffUTF16 detects BOM-less UTF-16 of mostly ASCII text by NUL bytes in every other position.
+func sniffUTF16(data []byte) (binary.ByteOrder, bool) {
+	n := len(data)
+	if n > 4096 {
+		n = 4096
+	}
+	if n < 4 {
+		return nil, false
+	}
+	evenZero, oddZero := 0, 0
+	for i := 0; i+1 < n; i += 2 {
+		if data[i] == 0 {
+			evenZero++
+		}
+		if data[i+1] == 0 {
+			oddZero++
+		}
+	}
+	pairs := n / 2
+	switch {
+	case oddZero*10 >= pairs*9 && evenZero == 0:
+		return binary.LittleEndian, true
+	case evenZero*10 >= pairs*9 && oddZero == 0:
+		return binary.BigEndian, true
+	}
+	return nil, false
+}
+
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// shingles splits normalized text into tokens (words, with each CJK character as its own token)
+// and returns the hashes of all runs of textShingleSize consecutive tokens.
+func shingles(text string) []uint64 {
+	var tokens []string
+	start := -1
+	for i, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			if start >= 0 {
+				tokens = append(tokens, text[start:i])
+				start = -1
+			}
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start < 0 {
+				start = i
+			}
+		default:
+			if start >= 0 {
+				tokens = append(tokens, text[start:i])
+				start = -1
+			}
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, text[start:])
+	}
+	k := textShingleSize
+	if len(tokens) < k {
+		k = len(tokens)
+	}
+	if k == 0 {
+		return nil
+	}
+	out := make([]uint64, 0, len(tokens)-k+1)
+	for i := 0; i+k <= len(tokens); i++ {
+		h := fnv.New64a()
+		for _, t := range tokens[i : i+k] {
+			h.Write([]byte(t))
+			h.Write([]byte{0})
+		}
+		out = append(out, h.Sum64())
+	}
+	return out
+}
+
+// minHashSignature keeps, for each of minHashSize seeded permutations, the minimum shingle hash.
+func minHashSignature(sh []uint64) []uint64 {
+	if len(sh) == 0 {
+		return nil
+	}
+	sig := make([]uint64, minHashSize)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for _, s := range sh {
+		for i := range sig {
+			if v := splitmix64(s ^ minHashSeeds[i]); v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// signatureSimilarity estimates Jaccard similarity as the fraction of equal signature slots.
+func signatureSimilarity(a, b []uint64) float64 {
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+var minHashSeeds = func() [minHashSize]uint64 {
+	var seeds [minHashSize]uint64
+	x := uint64(0x9E3779B97F4A7C15)
+	for i := range seeds {
+		x = splitmix64(x)
+		seeds[i] = x
+	}
+	return seeds
+}()
+
+func splitmix64(x uint64) uint64 {
+	x += 0x9E3779B97F4A7C15
+	x = (x ^ (x >> 30)) * 0xBF58476D1CE4E5B9
+	x = (x ^ (x >> 27)) * 0x94D049BB133111EB
+	return x ^ (x >> 31)
+}
+
+func readAllLimited(src source, path string, limit int64) ([]byte, error) {
+	f, err := src.open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, limit))
+}
+
+// unionFind tracks connected components over indexes.
+type unionFind struct{ parent []int }
+
+func newUnionFind(n int) *unionFind {
+	uf := &unionFind{parent: make([]int, n)}
+	for i := range uf.parent {
+		uf.parent[i] = i
+	}
+	return uf
+}
+
+func (u *unionFind) find(x int) int {
+	for u.parent[x] != x {
+		u.parent[x] = u.parent[u.parent[x]]
+		x = u.parent[x]
+	}
+	return x
+}
+
+// union joins the components of a and b, keeping the smaller index as root for determinism.
+func (u *unionFind) union(a, b int) {
+	ra, rb := u.find(a), u.find(b)
+	if ra == rb {
+		return
+	}
+	if rb < ra {
+		ra, rb = rb, ra
+	}
+	u.parent[rb] = ra
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// utf16Text encodes s as UTF-16 in order, led by a byte order mark if bom is set.
+func utf16Text(s string, order binary.ByteOrder, bom bool) string {
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+	out := make([]byte, 2*len(units))
+	for i, u := range units {
+		order.PutUint16(out[2*i:], u)
+	}
+	return string(out)
+}
+
+func TestNormalizeText(t *testing.T) {
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String("中文 文本")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data string
+		want string
+		ok   bool
+	}{
+		{name: "utf-8", data: "héllo world", want: "héllo world", ok: true},
+		{name: "utf-8 with bom", data: "\xEF\xBB\xBFhéllo world", want: "héllo world", ok: true},
+		{name: "utf-16le with bom", data: utf16Text("héllo 世界", binary.LittleEndian, true), want: "héllo 世界", ok: true},
+		{name: "utf-16be with bom", data: utf16Text("héllo 世界", binary.BigEndian, true), want: "héllo 世界", ok: true},
+		{name: "utf-16le without bom", data: utf16Text("hello world", binary.LittleEndian, false), want: "hello world", ok: true},
+		{name: "utf-16be without bom", data: utf16Text("hello world", binary.BigEndian, false), want: "hello world", ok: true},
+		{name: "gbk", data: gbk, want: "中文 文本", ok: true},
+		{name: "line endings and whitespace runs", data: "  one\r\ntwo\t\tthree \r four\n\n", want: "one two three four", ok: true},
+		{name: "unicode spaces", data: "one 　two", want: "one two", ok: true},
+		{name: "empty", data: "", want: "", ok: true},
+		{name: "binary with nul bytes", data: "\x89\x00\x01\xC3\x28\x00\xA0\x00\x00\x07", ok: false},
+		{name: "binary without nul bytes", data: "\xFF\xFF\x80\xFF\x81\xFF", ok: false},
+		{name: "invalid utf-8 after a bom", data: "\xEF\xBB\xBF\xC3\x28", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NormalizeText([]byte(tt.data))
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("NormalizeText = %q, %v; want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSniffUTF16(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want binary.ByteOrder
+	}{
+		{name: "little endian", data: utf16Text("plain ascii text", binary.LittleEndian, false), want: binary.LittleEndian},
+		{name: "big endian", data: utf16Text("plain ascii text", binary.BigEndian, false), want: binary.BigEndian},
+		{name: "utf-8", data: "plain ascii text"},
+		{name: "too short", data: "a\x00"},
+		{name: "nul bytes on both sides", data: "\x00\x00a\x00b\x00c\x00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, ok := sniffUTF16([]byte(tt.data))
+			if ok != (tt.want != nil) || order != tt.want {
+				t.Errorf("sniffUTF16 = %v, %v; want %v", order, ok, tt.want)
+			}
+		})
+	}
+}
+
+// words returns a document of n distinct words starting with prefix.
+func words(prefix string, n int) string {
+	w := make([]string, n)
+	for i := range w {
+		w[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return strings.Join(w, " ")
+}
+
+func TestTextSimilarity(t *testing.T) {
+	doc := words("alpha", 200)
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name:  "one word changed",
+			files: map[string]string{"a.txt": doc, "b.txt": strings.Replace(doc, "alpha100 ", "omega ", 1)},
+			want:  []string{"text a.txt b.txt"},
+		},
+		{
+			name: "other encoding and line breaks",
+			files: map[string]string{
+				"a.txt": doc,
+				"b.txt": utf16Text(strings.ReplaceAll(doc, " ", "\r\n"), binary.LittleEndian, true),
+			},
+			want: []string{"text a.txt b.txt"},
+		},
+		{
+			name:  "different documents",
+			files: map[string]string{"a.txt": doc, "b.txt": words("beta", 200)},
+		},
+		{
+			name:  "half the words shared",
+			files: map[string]string{"a.txt": doc, "b.txt": words("alpha", 100) + " " + words("beta", 100)},
+		},
+		{
+			name:  "binary content",
+			files: map[string]string{"a.txt": doc, "b.bin": "\x00\x01\x02\x03" + doc},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, rep := scanFS(t, TextEngine{}, textFS(tt.files), ScanConfig{SimilarityThreshold: 0.8})
+			checkGroups(t, groups, tt.want)
+			if rep.Count() != 0 {
+				t.Errorf("errors %v", rep.Errors)
+			}
+		})
+	}
+}
+
+func TestTextSimilarityReportsLargeFiles(t *testing.T) {
+	big := strings.Repeat("x", maxTextBytes+1)
+	groups, rep := scanFS(t, TextEngine{}, textFS(map[string]string{"a.txt": big, "b.txt": big}), ScanConfig{})
+	checkGroups(t, groups, nil)
+	if counts := rep.ByCategory(); rep.Count() != 2 || counts[ErrorTooLarge] != 2 {
+		t.Errorf("errors %v", rep.Errors)
+	}
+}
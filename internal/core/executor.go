@@ -2,6 +2,7 @@ package core
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -18,6 +19,7 @@ type ExecLogEntry struct {
 	Target   string
 	Status   string // success|fail|skipped
 	Message  string
+	IsDir    bool `json:",omitempty"` // Source is a whole directory
 }
 
 // ExecResult wraps the execution log and potential undo info placeholder.
@@ -30,14 +32,18 @@ func DryRunExecute(plan []PlanItem) ExecResult {
 	logs := make([]ExecLogEntry, 0, len(plan))
 	now := time.Now().Unix()
 	for _, p := range plan {
-		logs = append(logs, ExecLogEntry{
+		entry := ExecLogEntry{
 			TimeUnix: now,
 			Action:   p.Action,
 			Source:   p.Source.Path,
 			Target:   p.Target,
 			Status:   "success",
 			Message:  "dry-run",
-		})
+		}
+		if p.Source.Archive != "" {
+			entry.Status, entry.Message = "fail", archiveMemberMessage(p.Source)
+		}
+		logs = append(logs, entry)
 	}
 	return ExecResult{Entries: logs}
 }
@@ -64,11 +70,21 @@ func Execute(plan []PlanItem, opts ExecuteOptions) ExecResult {
 	}
 	logs := make([]ExecLogEntry, 0, len(plan))
 	for _, p := range plan {
-		entry := ExecLogEntry{TimeUnix: time.Now().Unix(), Action: p.Action, Source: p.Source.Path, Target: p.Target}
+		entry := ExecLogEntry{TimeUnix: time.Now().Unix(), Action: p.Action, Source: p.Source.Path, Target: p.Target, IsDir: p.Source.IsDir}
+		if p.Source.Archive != "" {
+			entry.Status = "fail"
+			entry.Message = archiveMemberMessage(p.Source)
+			logs = append(logs, entry)
+			continue
+		}
 		var err error
 		switch p.Action {
 		case ActionDelete:
-			err = os.Remove(p.Source.Path)
+			if p.Source.IsDir {
+				err = os.RemoveAll(p.Source.Path)
+			} else {
+				err = os.Remove(p.Source.Path)
+			}
 		case ActionMove:
 			if p.Target == "" {
 				err = os.ErrInvalid
@@ -107,7 +123,11 @@ func Execute(plan []PlanItem, opts ExecuteOptions) ExecResult {
 				logs = append(logs, entry)
 				continue
 			}
-			err = copyFile(p.Source.Path, targetPath)
+			if p.Source.IsDir {
+				err = copyTree(p.Source.Path, targetPath)
+			} else {
+				err = copyFile(p.Source.Path, targetPath)
+			}
 			if err == nil {
 				entry.Target = targetPath
 			}
@@ -185,6 +205,12 @@ func resolveConflict(target string, policy ConflictPolicy) (string, error) {
 	}
 }
 
+// archiveMemberMessage explains why an action on a virtual archive member was refused: the
+// member is not a file on disk, and rewriting the archive is out of scope.
+func archiveMemberMessage(f FileInfo) string {
+	return fmt.Sprintf("%s is inside archive %s; act on the archive itself", f.memberName(), f.Archive)
+}
+
 func copyFile(src, dst string) error {
 	sf, err := os.Open(src)
 	if err != nil {
@@ -200,14 +226,41 @@ func copyFile(src, dst string) error {
 	return err
 }
 
+// copyTree copies the directory src to dst, which must not exist yet.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.Mkdir(target, 0o755)
+		}
+		if !d.Type().IsRegular() {
+			return nil // links and special files are not copied
+		}
+		return copyFile(path, target)
+	})
+}
+
 // PersistExecLog saves the execution log to a JSON file in temp dir and returns the path.
+// Logs saved within the same second get a numbered name rather than replacing each other.
 func PersistExecLog(res ExecResult) (string, error) {
 	dir := filepath.Join(os.TempDir(), "haste_logs")
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return "", err
 	}
-	name := filepath.Join(dir, time.Now().Format("20060102_150405")+"_exec.json")
-	f, err := os.Create(name)
+	stamp := time.Now().Format("20060102_150405")
+	name := filepath.Join(dir, stamp+"_exec.json")
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	for i := 1; errors.Is(err, os.ErrExist); i++ {
+		name = filepath.Join(dir, stamp+"_"+itoa(i)+"_exec.json")
+		f, err = os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -225,7 +278,7 @@ func Undo(res ExecResult) ExecResult {
 	logs := make([]ExecLogEntry, 0, len(res.Entries))
 	for i := len(res.Entries) - 1; i >= 0; i-- {
 		e := res.Entries[i]
-		entry := ExecLogEntry{TimeUnix: time.Now().Unix(), Action: e.Action, Source: e.Source, Target: e.Target}
+		entry := ExecLogEntry{TimeUnix: time.Now().Unix(), Action: e.Action, Source: e.Source, Target: e.Target, IsDir: e.IsDir}
 		var err error
 		switch e.Action {
 		case ActionMove:
@@ -237,7 +290,9 @@ func Undo(res ExecResult) ExecResult {
 				err = os.Rename(e.Target, e.Source)
 			}
 		case ActionCopy:
-			if e.Target != "" {
+			if e.Target != "" && e.IsDir {
+				err = os.RemoveAll(e.Target)
+			} else if e.Target != "" {
 				err = os.Remove(e.Target)
 			}
 		case ActionDelete:
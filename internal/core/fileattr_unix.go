@@ -0,0 +1,14 @@
+//go:build !windows
+
+package core
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// fileAttributes reports whether the file is hidden (a dot name) or a system file.
+// Unix has no system attribute.
+func fileAttributes(name string, info fs.FileInfo) (hidden, system bool) {
+	return strings.HasPrefix(name, ".") && name != "." && name != "..", false
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package core
+
+import (
+	"io/fs"
+	"strings"
+	"syscall"
+)
+
+// fileAttributes reports the hidden and system attributes of the file. Dot names count as
+// hidden too, as they usually come from Unix tools.
+func fileAttributes(name string, info fs.FileInfo) (hidden, system bool) {
+	hidden = strings.HasPrefix(name, ".") && name != "." && name != ".."
+	if d, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		hidden = hidden || d.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+		system = d.FileAttributes&syscall.FILE_ATTRIBUTE_SYSTEM != 0
+	}
+	return hidden, system
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package core
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileIdentity returns the device and inode numbers of the file.
+func fileIdentity(path string, info fs.FileInfo) (dev, ino uint64) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev), uint64(st.Ino)
+	}
+	return 0, 0
+}
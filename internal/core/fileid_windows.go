@@ -0,0 +1,36 @@
+//go:build windows
+
+package core
+
+import (
+	"hash/fnv"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// fileIdentity returns the volume serial number and file index of the file.
+// If the file cannot be opened the volume is identified by its name and the index is 0.
+func fileIdentity(path string, info fs.FileInfo) (dev, ino uint64) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err == nil {
+		h, err := syscall.CreateFile(p, 0,
+			syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+			nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+		if err == nil {
+			defer syscall.CloseHandle(h)
+			var d syscall.ByHandleFileInformation
+			if syscall.GetFileInformationByHandle(h, &d) == nil {
+				return uint64(d.VolumeSerialNumber), uint64(d.FileIndexHigh)<<32 | uint64(d.FileIndexLow)
+			}
+		}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	hv := fnv.New64a()
+	hv.Write([]byte(strings.ToUpper(filepath.VolumeName(abs))))
+	return hv.Sum64(), 0
+}
@@ -0,0 +1,295 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// FileClass is a coarse file type detected from the leading bytes of the content.
+type FileClass string
+
+const (
+	ClassImage    FileClass = "image"
+	ClassVideo    FileClass = "video"
+	ClassAudio    FileClass = "audio"
+	ClassDocument FileClass = "document" // PDF, office files, e-books and plain text
+	ClassArchive  FileClass = "archive"
+	ClassCode     FileClass = "code" // text with a shebang or a source-code extension
+	ClassOther    FileClass = "other"
+)
+
+// FileClasses lists the classes that can be selected in ScanConfig.TypeClasses.
+func FileClasses() []FileClass {
+	return []FileClass{ClassImage, ClassVideo, ClassAudio, ClassDocument, ClassArchive, ClassCode}
+}
+
+// MIMEOctetStream is the type of content that matched no signature and is not text.
+const MIMEOctetStream = "application/octet-stream"
+
+// sniffBytes is how much of a file is read to detect its type; tar headers need 262 bytes
+// and office files name their first zip member after offset 30.
+const sniffBytes = 4096
+
+// DetectFileType reads the start of the file and returns its MIME type and class, detected
+// from magic bytes. The extension is only consulted to tell source code from other text.
+func DetectFileType(path string) (string, FileClass, error) {
+	return detectType(source{}, FileInfo{Path: path})
+}
+
+// detectType is DetectFileType for files of any source and archive members alike.
+func detectType(src source, fi FileInfo) (string, FileClass, error) {
+	f, err := src.openContent(fi)
+	if err != nil {
+		return MIMEOctetStream, ClassOther, err
+	}
+	defer f.Close()
+	head := make([]byte, sniffBytes)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return MIMEOctetStream, ClassOther, err
+	}
+	name := fi.Path
+	if fi.Archive != "" {
+		name = fi.memberName()
+	}
+	mime, class := sniffType(head[:n], name)
+	return mime, class, nil
+}
+
+// sniffHead returns the part of data that sniffType looks at.
+func sniffHead(data []byte) []byte {
+	if len(data) > sniffBytes {
+		return data[:sniffBytes]
+	}
+	return data
+}
+
+// sniffType classifies content by its leading bytes.
+func sniffType(head []byte, name string) (string, FileClass) {
+	has := func(off int, sig string) bool {
+		return len(head) >= off+len(sig) && string(head[off:off+len(sig)]) == sig
+	}
+	switch {
+	case has(0, "\xFF\xD8\xFF"):
+		return "image/jpeg", ClassImage
+	case has(0, "\x89PNG\r\n\x1A\n"):
+		return "image/png", ClassImage
+	case has(0, "GIF87a"), has(0, "GIF89a"):
+		return "image/gif", ClassImage
+	case has(0, "BM") && has(6, "\x00\x00\x00\x00"):
+		return "image/bmp", ClassImage
+	case has(0, "RIFF") && has(8, "WEBP"):
+		return "image/webp", ClassImage
+	case has(0, "II*\x00"), has(0, "MM\x00*"):
+		return "image/tiff", ClassImage
+	case has(0, "\x00\x00\x01\x00"):
+		return "image/x-icon", ClassImage
+	case has(4, "ftyp"):
+		return sniffFtyp(head)
+	case has(0, "\x1A\x45\xDF\xA3"):
+		if bytes.Contains(head, []byte("webm")) {
+			return "video/webm", ClassVideo
+		}
+		return "video/x-matroska", ClassVideo
+	case has(0, "RIFF") && has(8, "AVI "):
+		return "video/x-msvideo", ClassVideo
+	case has(0, "FLV\x01"):
+		return "video/x-flv", ClassVideo
+	case has(0, "\x00\x00\x01\xBA"), has(0, "\x00\x00\x01\xB3"):
+		return "video/mpeg", ClassVideo
+	case has(0, "\x30\x26\xB2\x75\x8E\x66\xCF\x11"):
+		return "video/x-ms-asf", ClassVideo
+	case len(head) > 376 && head[0] == 0x47 && head[188] == 0x47 && head[376] == 0x47: // MPEG-TS packets
+		return "video/mp2t", ClassVideo
+	case has(0, "ID3"):
+		return "audio/mpeg", ClassAudio
+	case has(0, "fLaC"):
+		return "audio/flac", ClassAudio
+	case has(0, "OggS"):
+		return "audio/ogg", ClassAudio
+	case has(0, "RIFF") && has(8, "WAVE"):
+		return "audio/wav", ClassAudio
+	case has(0, "MThd"):
+		return "audio/midi", ClassAudio
+	case has(0, "FORM") && has(8, "AIFF"):
+		return "audio/aiff", ClassAudio
+	case has(0, "#!AMR"):
+		return "audio/amr", ClassAudio
+	case len(head) >= 2 && head[0] == 0xFF && (head[1] == 0xF1 || head[1] == 0xF9): // ADTS frame sync
+		return "audio/aac", ClassAudio
+	case len(head) >= 2 && head[0] == 0xFF && bytes.IndexByte([]byte{0xFB, 0xFA, 0xF3, 0xF2, 0xE3}, head[1]) >= 0: // MP3 frame sync
+		return "audio/mpeg", ClassAudio
+	case has(0, "%PDF-"):
+		return "application/pdf", ClassDocument
+	case has(0, "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1"):
+		return "application/x-ole-storage", ClassDocument // legacy .doc/.xls/.ppt
+	case has(0, "{\\rtf"):
+		return "application/rtf", ClassDocument
+	case has(0, "PK\x03\x04"):
+		return sniffZip(head, has)
+	case has(0, "Rar!\x1A\x07"):
+		return "application/vnd.rar", ClassArchive
+	case has(0, "7z\xBC\xAF\x27\x1C"):
+		return "application/x-7z-compressed", ClassArchive
+	case has(0, "\x1F\x8B"):
+		return "application/gzip", ClassArchive
+	case has(0, "BZh"):
+		return "application/x-bzip2", ClassArchive
+	case has(0, "\xFD7zXZ\x00"):
+		return "application/x-xz", ClassArchive
+	case has(0, "\x28\xB5\x2F\xFD"):
+		return "application/zstd", ClassArchive
+	case has(257, "ustar"):
+		return "application/x-tar", ClassArchive
+	case has(0, "MSCF"):
+		return "application/vnd.ms-cab-compressed", ClassArchive
+	case has(0, "!<arch>"):
+		return "application/x-archive", ClassArchive
+	}
+	if !looksLikeText(head) {
+		return MIMEOctetStream, ClassOther
+	}
+	mime := "text/plain"
+	trimmed := bytes.ToLower(bytes.TrimLeft(head, " \t\r\n\xEF\xBB\xBF"))
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<!doctype html")), bytes.HasPrefix(trimmed, []byte("<html")):
+		mime = "text/html"
+	case bytes.HasPrefix(trimmed, []byte("<?xml")):
+		mime = "text/xml"
+	}
+	if has(0, "#!") || codeExtensions[strings.ToLower(filepath.Ext(name))] {
+		return mime, ClassCode
+	}
+	return mime, ClassDocument
+}
+
+// sniffFtyp reads the major brand of an ISO base media file (MP4, MOV, HEIC, M4A...).
+func sniffFtyp(head []byte) (string, FileClass) {
+	brand := ""
+	if len(head) >= 12 {
+		brand = string(head[8:12])
+	}
+	switch brand {
+	case "heic", "heix", "hevc", "heim", "heis":
+		return "image/heic", ClassImage
+	case "mif1", "msf1":
+		return "image/heif", ClassImage
+	case "avif", "avis":
+		return "image/avif", ClassImage
+	case "M4A ", "M4B ", "M4P ", "F4A ":
+		return "audio/mp4", ClassAudio
+	case "qt  ":
+		return "video/quicktime", ClassVideo
+	}
+	if strings.HasPrefix(brand, "3gp") || strings.HasPrefix(brand, "3g2") {
+		return "video/3gpp", ClassVideo
+	}
+	return "video/mp4", ClassVideo
+}
+
+// sniffZip tells office, OpenDocument and EPUB files, which are zip containers with a
+// telltale first member, from plain zip archives.
+func sniffZip(head []byte, has func(int, string) bool) (string, FileClass) {
+	switch {
+	case has(30, "word/"):
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document", ClassDocument
+	case has(30, "xl/"):
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ClassDocument
+	case has(30, "ppt/"):
+		return "application/vnd.openxmlformats-officedocument.presentationml.presentation", ClassDocument
+	case has(30, "[Content_Types].xml"):
+		// the member that names the document part comes later; look for it in the head
+		switch {
+		case bytes.Contains(head, []byte("word/")):
+			return "application/vnd.openxmlformats-officedocument.wordprocessingml.document", ClassDocument
+		case bytes.Contains(head, []byte("xl/")):
+			return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ClassDocument
+		case bytes.Contains(head, []byte("ppt/")):
+			return "application/vnd.openxmlformats-officedocument.presentationml.presentation", ClassDocument
+		}
+		return "application/zip", ClassDocument
+	case has(30, "mimetype"):
+		// OpenDocument and EPUB store their MIME type uncompressed as the first member
+		mime := head[38:]
+		if i := bytes.Index(mime, []byte("PK")); i >= 0 {
+			mime = mime[:i]
+		}
+		if s := string(mime); strings.HasPrefix(s, "application/") {
+			return s, ClassDocument
+		}
+	}
+	return "application/zip", ClassArchive
+}
+
+// looksLikeText reports whether head is plausibly text: UTF-16, or bytes without NULs and with
+// few control characters.
+func looksLikeText(head []byte) bool {
+	if len(head) == 0 {
+		return false
+	}
+	if bytes.HasPrefix(head, []byte{0xFF, 0xFE}) || bytes.HasPrefix(head, []byte{0xFE, 0xFF}) {
+		return true
+	}
+	if _, ok := sniffUTF16(head); ok {
+		return true
+	}
+	control := 0
+	for _, c := range head {
+		switch {
+		case c == 0:
+			return false
+		case c < 0x20 && c != '\n' && c != '\r' && c != '\t' && c != '\f' && c != 0x1B:
+			control++
+		}
+	}
+	return control*100 < len(head)
+}
+
+var codeExtensions = map[string]bool{
+	".go": true, ".c": true, ".h": true, ".cc": true, ".cpp": true, ".hpp": true, ".cs": true,
+	".java": true, ".kt": true, ".scala": true, ".py": true, ".rb": true, ".php": true, ".pl": true,
+	".js": true, ".mjs": true, ".ts": true, ".tsx": true, ".jsx": true, ".vue": true, ".rs": true,
+	".swift": true, ".m": true, ".lua": true, ".sh": true, ".bash": true, ".ps1": true, ".bat": true,
+	".sql": true, ".r": true, ".dart": true, ".css": true, ".scss": true, ".html": true, ".htm": true,
+	".xml": true, ".json": true, ".yaml": true, ".yml": true, ".toml": true, ".ini": true,
+}
+
+// decodableImages are the image types the registered decoders can read.
+var decodableImages = map[string]bool{
+	"image/jpeg": true, "image/png": true, "image/gif": true,
+	"image/bmp": true, "image/webp": true, "image/tiff": true,
+}
+
+// fileType returns the MIME type and class of f: the values already on f, those cached in idx,
+// or else sniffed from the file. Sniffed types are stored in idx.
+func (l *limits) fileType(idx *HashIndex, f FileInfo) (string, FileClass, error) {
+	if f.Type != "" && f.Class != "" {
+		return f.Type, f.Class, nil
+	}
+	if mime, class, ok := idx.fileType(f); ok {
+		return mime, class, nil
+	}
+	l.acquireIO(f.Device)
+	mime, class, err := detectType(l.src, f)
+	l.releaseIO(f.Device)
+	if err != nil {
+		return mime, class, err
+	}
+	idx.putFileType(f, mime, class)
+	return mime, class, nil
+}
+
+// detectTypes fills in Type and Class of every file. Unreadable files are reported and keep
+// empty types, so no type-based selection picks them.
+func detectTypes(lim *limits, idx *HashIndex, files []FileInfo) {
+	lim.forEach(len(files), func(i int) {
+		mime, class, err := lim.fileType(idx, files[i])
+		if err != nil {
+			lim.rep.addIO(files[i].Path, "walking", err)
+			return
+		}
+		files[i].Type, files[i].Class = mime, class
+	})
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// at returns n bytes of padding with sig written at off.
+func at(n, off int, sig string) string {
+	b := []byte(strings.Repeat("\x01", n))
+	copy(b[off:], sig)
+	return string(b)
+}
+
+// zipHead is the start of a zip file whose first member is named name.
+func zipHead(name string) string {
+	return "PK\x03\x04" + strings.Repeat("\x00", 26) + name
+}
+
+func TestSniffType(t *testing.T) {
+	ts := strings.Repeat("\x01", 400)
+	ts = "\x47" + ts[1:188] + "\x47" + ts[189:376] + "\x47" + ts[377:]
+	tests := []struct {
+		name  string
+		head  string
+		mime  string
+		class FileClass
+	}{
+		{name: "x.bin", head: "\xFF\xD8\xFF\xE0", mime: "image/jpeg", class: ClassImage},
+		{name: "x", head: "\x89PNG\r\n\x1A\n", mime: "image/png", class: ClassImage},
+		{name: "x", head: "GIF89a", mime: "image/gif", class: ClassImage},
+		{name: "x", head: "RIFF\x00\x00\x00\x00WEBPVP8 ", mime: "image/webp", class: ClassImage},
+		{name: "x", head: "\x00\x00\x00\x18ftypheic", mime: "image/heic", class: ClassImage},
+		{name: "x", head: "\x00\x00\x00\x18ftypisom", mime: "video/mp4", class: ClassVideo},
+		{name: "x", head: "\x00\x00\x00\x14ftypqt  ", mime: "video/quicktime", class: ClassVideo},
+		{name: "x", head: "\x00\x00\x00\x18ftypM4A ", mime: "audio/mp4", class: ClassAudio},
+		{name: "x", head: "\x1A\x45\xDF\xA3\x01webm", mime: "video/webm", class: ClassVideo},
+		{name: "x", head: "\x1A\x45\xDF\xA3\x01matroska", mime: "video/x-matroska", class: ClassVideo},
+		{name: "x", head: ts, mime: "video/mp2t", class: ClassVideo},
+		{name: "x", head: "ID3\x04", mime: "audio/mpeg", class: ClassAudio},
+		{name: "x", head: "\xFF\xFB\x90\x00", mime: "audio/mpeg", class: ClassAudio},
+		{name: "x", head: "RIFF\x00\x00\x00\x00WAVEfmt ", mime: "audio/wav", class: ClassAudio},
+		{name: "x", head: "%PDF-1.7", mime: "application/pdf", class: ClassDocument},
+		{name: "x", head: zipHead("word/document.xml"), mime: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", class: ClassDocument},
+		{name: "x", head: zipHead("[Content_Types].xml") + "...xl/workbook.xml", mime: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", class: ClassDocument},
+		{name: "x", head: zipHead("mimetypeapplication/epub+zipPK"), mime: "application/epub+zip", class: ClassDocument},
+		{name: "x", head: zipHead("photos/a.jpg"), mime: "application/zip", class: ClassArchive},
+		{name: "x", head: "\x1F\x8B\x08", mime: "application/gzip", class: ClassArchive},
+		{name: "x", head: at(512, 257, "ustar\x0000"), mime: "application/x-tar", class: ClassArchive},
+		{name: "notes", head: "plain words\n", mime: "text/plain", class: ClassDocument},
+		{name: "main.GO", head: "package main\n", mime: "text/plain", class: ClassCode},
+		{name: "run", head: "#!/bin/sh\necho hi\n", mime: "text/plain", class: ClassCode},
+		{name: "page", head: "\xEF\xBB\xBF  <!DOCTYPE html><html>", mime: "text/html", class: ClassDocument},
+		{name: "page.html", head: "<html></html>", mime: "text/html", class: ClassCode},
+		{name: "feed", head: "<?xml version=\"1.0\"?>", mime: "text/xml", class: ClassDocument},
+		{name: "x", head: "\x00\x01\x02\x03", mime: MIMEOctetStream, class: ClassOther},
+		{name: "x", head: "", mime: MIMEOctetStream, class: ClassOther},
+	}
+	for _, tt := range tests {
+		mime, class := sniffType([]byte(tt.head), tt.name)
+		if mime != tt.mime || class != tt.class {
+			t.Errorf("sniffType(%q, %q) = %s, %s; want %s, %s", tt.head, tt.name, mime, class, tt.mime, tt.class)
+		}
+	}
+}
+
+func TestLooksLikeText(t *testing.T) {
+	tests := []struct {
+		head string
+		want bool
+	}{
+		{"plain text\r\n\twith tabs\f", true},
+		{"héllo, 世界", true},
+		{"\x1B[1mansi escapes\x1B[0m", true},
+		{"\xFF\xFEh\x00i\x00", true},
+		{"h\x00e\x00l\x00l\x00o\x00", true},
+		{"", false},
+		{"text\x00with a nul", false},
+		{"\x01\x02\x03" + strings.Repeat("a", 100), false},
+		{"\x01" + strings.Repeat("a", 100), true},
+	}
+	for _, tt := range tests {
+		if got := looksLikeText([]byte(tt.head)); got != tt.want {
+			t.Errorf("looksLikeText(%q) = %v, want %v", tt.head, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// ParseTimeBound parses a modification time bound for ScanConfig.ModifiedAfterUnix or
+// ModifiedBeforeUnix: a date (2006-01-02), a local date and time (2006-01-02 15:04) or RFC 3339.
+// An empty string yields 0, meaning no bound.
+func ParseTimeBound(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Unix(), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time %q, want YYYY-MM-DD[ HH:MM]", s)
+}
+
+// NormalizeExtensions lowercases extensions and adds the leading dot: "JPG" becomes ".jpg".
+// Multi-part extensions such as "tar.gz" are kept whole.
+func NormalizeExtensions(exts []string) []string {
+	var out []string
+	for _, e := range exts {
+		e = strings.ToLower(strings.TrimSpace(e))
+		e = strings.TrimPrefix(e, "*")
+		if e == "" || e == "." {
+			continue
+		}
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// fileFilter applies the metadata filters of a ScanConfig during the walk.
+type fileFilter struct {
+	minSize, maxSize    int64
+	after, before       int64
+	include, exclude    []string
+	skipHidden, skipSys bool
+	classes             map[FileClass]bool
+}
+
+func newFileFilter(config ScanConfig) (*fileFilter, error) {
+	ff := &fileFilter{
+		minSize:    config.MinSizeBytes,
+		maxSize:    config.MaxSizeBytes,
+		after:      config.ModifiedAfterUnix,
+		before:     config.ModifiedBeforeUnix,
+		include:    NormalizeExtensions(config.IncludeExtensions),
+		exclude:    NormalizeExtensions(config.ExcludeExtensions),
+		skipHidden: config.SkipHidden,
+		skipSys:    config.SkipSystem,
+	}
+	if len(config.TypeClasses) > 0 {
+		ff.classes = map[FileClass]bool{}
+		for _, c := range config.TypeClasses {
+			c = FileClass(strings.ToLower(string(c)))
+			if c != ClassOther && !validClass(c) {
+				return nil, fmt.Errorf("unknown file class %q", c)
+			}
+			ff.classes[c] = true
+		}
+	}
+	return ff, nil
+}
+
+func validClass(c FileClass) bool {
+	for _, k := range FileClasses() {
+		if k == c {
+			return true
+		}
+	}
+	return false
+}
+
+// skipDir reports whether a directory below the root is pruned by the attribute toggles.
+func (ff *fileFilter) skipDir(name string, info fs.FileInfo) bool {
+	if !ff.skipHidden && !ff.skipSys {
+		return false
+	}
+	hidden, system := fileAttributes(name, info)
+	return (ff.skipHidden && hidden) || (ff.skipSys && system)
+}
+
+// keep reports whether a regular file passes the size, time, extension and attribute filters.
+func (ff *fileFilter) keep(name string, info fs.FileInfo) bool {
+	size := info.Size()
+	if (ff.minSize > 0 && size < ff.minSize) || (ff.maxSize > 0 && size > ff.maxSize) {
+		return false
+	}
+	mod := info.ModTime().Unix()
+	if (ff.after > 0 && mod < ff.after) || (ff.before > 0 && mod >= ff.before) {
+		return false
+	}
+	lower := strings.ToLower(name)
+	if len(ff.include) > 0 && !hasExtension(lower, ff.include) {
+		return false
+	}
+	if hasExtension(lower, ff.exclude) {
+		return false
+	}
+	return !ff.skipDir(name, info)
+}
+
+func hasExtension(lowerName string, exts []string) bool {
+	for _, e := range exts {
+		if strings.HasSuffix(lowerName, e) && len(lowerName) > len(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterClasses keeps the files of the selected classes; detectTypes must have run.
+func filterClasses(files []FileInfo, classes map[FileClass]bool) []FileInfo {
+	if len(classes) == 0 {
+		return files
+	}
+	out := files[:0]
+	for _, f := range files {
+		if classes[f.Class] {
+			out = append(out, f)
+		}
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFileFilter(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC) }
+	fsys := fstest.MapFS{
+		"small.txt":     {Data: []byte("hi"), ModTime: day(1)},
+		"large.txt":     {Data: make([]byte, 100), ModTime: day(2)},
+		"photo.JPG":     {Data: []byte("\xFF\xD8\xFF\xE0 not really"), ModTime: day(3)},
+		"notes.md":      {Data: []byte("# notes\n"), ModTime: day(4)},
+		"main.go":       {Data: []byte("package main\n"), ModTime: day(5)},
+		".hidden":       {Data: []byte("secret"), ModTime: day(6)},
+		".git/config":   {Data: []byte("[core]\n"), ModTime: day(7)},
+		"a.tar.gz":      {Data: []byte("\x1F\x8B not really"), ModTime: day(8)},
+		"dir/photo.jpg": {Data: []byte("\xFF\xD8\xFF\xE0 again"), ModTime: day(9)},
+	}
+	tests := []struct {
+		name   string
+		config ScanConfig
+		want   []string
+	}{
+		{
+			name:   "everything",
+			config: ScanConfig{},
+			want:   []string{".git/config", ".hidden", "a.tar.gz", "dir/photo.jpg", "large.txt", "main.go", "notes.md", "photo.JPG", "small.txt"},
+		},
+		{
+			name:   "size bounds",
+			config: ScanConfig{MinSizeBytes: 8, MaxSizeBytes: 20},
+			want:   []string{"notes.md", "photo.JPG", "dir/photo.jpg", "a.tar.gz", "main.go"},
+		},
+		{
+			name:   "included extensions ignore case",
+			config: ScanConfig{IncludeExtensions: []string{"jpg", "*.MD"}},
+			want:   []string{"dir/photo.jpg", "notes.md", "photo.JPG"},
+		},
+		{
+			name:   "excluded multi-part extension",
+			config: ScanConfig{ExcludeExtensions: []string{".tar.gz", "txt"}},
+			want:   []string{".git/config", ".hidden", "dir/photo.jpg", "main.go", "notes.md", "photo.JPG"},
+		},
+		{
+			name:   "modified window",
+			config: ScanConfig{ModifiedAfterUnix: day(3).Unix(), ModifiedBeforeUnix: day(5).Unix()},
+			want:   []string{"notes.md", "photo.JPG"},
+		},
+		{
+			name:   "hidden files and directories",
+			config: ScanConfig{SkipHidden: true},
+			want:   []string{"a.tar.gz", "dir/photo.jpg", "large.txt", "main.go", "notes.md", "photo.JPG", "small.txt"},
+		},
+		{
+			name:   "classes by content",
+			config: ScanConfig{TypeClasses: []FileClass{"IMAGE", ClassCode}},
+			want:   []string{"dir/photo.jpg", "main.go", "photo.JPG"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := walkFS(t, fsys, tt.config, walkOptions{})
+			got := map[string]bool{}
+			for _, p := range walked(r) {
+				got[p] = true
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("walked %d files, want %d: %v", len(got), len(tt.want), got)
+			}
+			for _, p := range tt.want {
+				if !got[p] {
+					t.Errorf("%s filtered out", p)
+				}
+			}
+		})
+	}
+}
+
+func TestFileFilterRejectsUnknownClasses(t *testing.T) {
+	if _, err := newFileFilter(ScanConfig{TypeClasses: []FileClass{"pictures"}}); err == nil {
+		t.Error("no error for an unknown class")
+	}
+}
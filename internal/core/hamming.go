@@ -0,0 +1,149 @@
+package core
+
+import (
+	"math/bits"
+	"strconv"
+)
+
+// hammingIndex finds the 64-bit hashes within a Hamming radius of a query without comparing it
+// to every hash, using multi-index hashing: the hashes are cut into m segments, and two hashes
+// that differ in at most radius bits differ in at most radius/m bits in one of the segments
+// (pigeonhole). Each segment has a table, and a query looks up every segment value within that
+// smaller radius; only the hashes found that way are compared in full. Buckets hold the hashes
+// themselves, so a query reads them in sequence rather than chasing positions.
+// The segment count is chosen from the expected number of hashes and the radius; when no split
+// beats a plain scan, as for small inputs or wide radii, the index scans.
+type hammingIndex struct {
+	radius int
+	segs   int  // 0 for a plain scan
+	width  uint // bits per segment
+	hashes []uint64
+	tables [][][]hammingEntry // per segment: segment value -> hashes with that value
+}
+
+type hammingEntry struct {
+	hash uint64
+	pos  int32
+}
+
+// newHammingIndex prepares an index for about n hashes searched within radius bits.
+func newHammingIndex(n, radius int) *hammingIndex {
+	x := &hammingIndex{radius: radius}
+	// costs of indexing and querying all n hashes, counted in comparisons
+	best := float64(n) * float64(n) // plain scan
+	// segments of at most 16 bits keep the tables flat arrays, so a probe costs about as much
+	// as a comparison; the arrays themselves must pay off too
+	for _, m := range []int{4, 8, 16} {
+		width := 64 / m
+		buckets := float64(uint64(1) << uint(width))
+		// per query: m tables, each probed with every value within the segment radius; a probe
+		// costs the lookup plus the expected bucket size
+		probes := float64(m) * ballSize(width, radius/m)
+		if cost := float64(n)*probes*(1+float64(n)/buckets) + float64(m)*buckets; cost < best {
+			best, x.segs, x.width = cost, m, uint(width)
+		}
+	}
+	x.tables = make([][][]hammingEntry, x.segs)
+	for i := range x.tables {
+		x.tables[i] = make([][]hammingEntry, 1<<x.width)
+	}
+	return x
+}
+
+// ballSize is the number of width-bit values within r bits of a value.
+func ballSize(width, r int) float64 {
+	total, c := 0.0, 1.0
+	for i := 0; i <= r && i <= width; i++ {
+		total += c
+		c = c * float64(width-i) / float64(i+1)
+	}
+	return total
+}
+
+func (x *hammingIndex) segment(h uint64, s int) uint64 {
+	return (h >> (uint(s) * x.width)) & (1<<x.width - 1)
+}
+
+// add indexes h under the next position, which it returns.
+func (x *hammingIndex) add(h uint64) int {
+	pos := int32(len(x.hashes))
+	x.hashes = append(x.hashes, h)
+	for s, t := range x.tables {
+		v := x.segment(h, s)
+		t[v] = append(t[v], hammingEntry{h, pos})
+	}
+	return int(pos)
+}
+
+// within calls fn with the position and distance of every indexed hash within the radius of q,
+// in no particular order. Queries may run concurrently with each other, not with add.
+func (x *hammingIndex) within(q uint64, fn func(pos, dist int)) {
+	if x.segs == 0 {
+		for pos, h := range x.hashes {
+			if d := bits.OnesCount64(q ^ h); d <= x.radius {
+				fn(pos, d)
+			}
+		}
+		return
+	}
+	r := x.radius / x.segs
+	for s, t := range x.tables {
+		forBall(x.segment(q, s), x.width, r, func(v uint64) {
+		next:
+			for _, e := range t[v] {
+				diff := q ^ e.hash
+				d := bits.OnesCount64(diff)
+				if d > x.radius {
+					continue
+				}
+				// a hash close enough in an earlier segment was reported there
+				for p := 0; p < s; p++ {
+					if bits.OnesCount64(x.segment(diff, p)) <= r {
+						continue next
+					}
+				}
+				fn(int(e.pos), d)
+			}
+		})
+	}
+}
+
+// forBall calls fn with every width-bit value within r bits of v.
+func forBall(v uint64, width uint, r int, fn func(uint64)) {
+	fn(v)
+	var flip func(v uint64, from uint, left int)
+	flip = func(v uint64, from uint, left int) {
+		for b := from; b < width; b++ {
+			w := v ^ 1<<b
+			fn(w)
+			if left > 1 {
+				flip(w, b+1, left-1)
+			}
+		}
+	}
+	if r > 0 {
+		flip(v, 0, r)
+	}
+}
+
+// parseHash reads a perceptual hash written as 16 hex digits.
+func parseHash(h string) (uint64, bool) {
+	v, err := strconv.ParseUint(h, 16, 64)
+	return v, err == nil && len(h) == 16
+}
+
+// parseHashes reads one or more perceptual hashes written back to back.
+func parseHashes(h string) ([]uint64, bool) {
+	if h == "" || len(h)%16 != 0 {
+		return nil, false
+	}
+	out := make([]uint64, 0, len(h)/16)
+	for i := 0; i < len(h); i += 16 {
+		v, ok := parseHash(h[i : i+16])
+		if !ok {
+			return nil, false
+		}
+		out = append(out, v)
+	}
+	return out, true
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"math/bits"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestHammingIndex(t *testing.T) {
+	tests := []struct {
+		segs, radius int // segs 0 scans
+	}{
+		{0, 10},
+		{4, 0}, {4, 3}, {4, 10}, {4, 21},
+		{8, 0}, {8, 7}, {8, 10}, {8, 24},
+		{16, 0}, {16, 15}, {16, 20}, {16, 40},
+	}
+	const n = 3000
+	for _, tt := range tests {
+		rng := rand.New(rand.NewSource(int64(tt.segs*100 + tt.radius)))
+		// random hashes with near copies, so every radius finds something
+		hashes := make([]uint64, n)
+		for i := range hashes {
+			if i > 0 && i%3 == 0 {
+				h := hashes[rng.Intn(i)]
+				for k := rng.Intn(tt.radius + 2); k > 0; k-- {
+					h ^= 1 << uint(rng.Intn(64))
+				}
+				hashes[i] = h
+				continue
+			}
+			hashes[i] = rng.Uint64()
+		}
+		// the segment count newHammingIndex would pick depends on n; each one is tried here
+		x := &hammingIndex{radius: tt.radius, segs: tt.segs}
+		if tt.segs > 0 {
+			x.width = uint(64 / tt.segs)
+			x.tables = make([][][]hammingEntry, tt.segs)
+			for i := range x.tables {
+				x.tables[i] = make([][]hammingEntry, 1<<x.width)
+			}
+		}
+		for i, h := range hashes {
+			if pos := x.add(h); pos != i {
+				t.Fatalf("add returned %d, want %d", pos, i)
+			}
+		}
+		for q := 0; q < n; q += 7 {
+			var got, want []int
+			x.within(hashes[q], func(pos, dist int) {
+				if d := bits.OnesCount64(hashes[q] ^ hashes[pos]); d != dist {
+					t.Errorf("segs=%d r=%d: distance %d reported as %d", tt.segs, tt.radius, d, dist)
+				}
+				got = append(got, pos)
+			})
+			for pos, h := range hashes {
+				if bits.OnesCount64(hashes[q]^h) <= tt.radius {
+					want = append(want, pos)
+				}
+			}
+			sort.Ints(got)
+			if !equalInts(got, want) {
+				t.Fatalf("segs=%d r=%d: query %d found %v, want %v", tt.segs, tt.radius, q, got, want)
+			}
+		}
+	}
+}
+
+func TestNewHammingIndexScansSmallInputs(t *testing.T) {
+	tests := []struct {
+		n, radius int
+		scan      bool
+	}{
+		{10, 10, true},
+		{2000, 64, true},
+		{100000, 10, false},
+	}
+	for _, tt := range tests {
+		if x := newHammingIndex(tt.n, tt.radius); (x.segs == 0) != tt.scan {
+			t.Errorf("n=%d r=%d: %d segments", tt.n, tt.radius, x.segs)
+		}
+	}
+}
+
+func TestForBall(t *testing.T) {
+	for _, r := range []int{0, 1, 2, 3} {
+		seen := map[uint64]bool{}
+		forBall(0b1010, 8, r, func(v uint64) {
+			if seen[v] {
+				t.Errorf("r=%d: %08b visited twice", r, v)
+			}
+			if v >= 1<<8 || bits.OnesCount64(v^0b1010) > r {
+				t.Errorf("r=%d: %08b is outside the ball", r, v)
+			}
+			seen[v] = true
+		})
+		if len(seen) != int(ballSize(8, r)) {
+			t.Errorf("r=%d: visited %d values, want %v", r, len(seen), ballSize(8, r))
+		}
+	}
+}
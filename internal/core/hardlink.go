@@ -0,0 +1,104 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// collapseHardlinks merges files that share a device and inode into one logical entry whose Links
+// hold the other paths. Duplicated paths (overlapping include roots) are dropped. Files with an
+// unknown inode are kept as they are.
+func collapseHardlinks(files []FileInfo) []FileInfo {
+	type key struct{ dev, ino uint64 }
+	out := make([]FileInfo, 0, len(files))
+	byInode := map[key]int{}
+	seen := map[string]bool{}
+	for _, f := range files {
+		if seen[f.Path] {
+			continue
+		}
+		seen[f.Path] = true
+		if f.Inode == 0 {
+			out = append(out, f)
+			continue
+		}
+		k := key{f.Device, f.Inode}
+		if i, ok := byInode[k]; ok {
+			out[i].Links = append(out[i].Links, f.Path)
+			continue
+		}
+		byInode[k] = len(out)
+		out = append(out, f)
+	}
+	return out
+}
+
+// linkedGroups reports hardlink sets whose inode is not part of any duplicate group, so the user
+// can see that these paths are one copy on disk rather than backups of each other.
+func linkedGroups(files []FileInfo, groups []DuplicateGroup) []DuplicateGroup {
+	grouped := map[string]bool{}
+	for _, g := range groups {
+		for _, f := range g.Files {
+			grouped[f.Path] = true
+		}
+	}
+	var out []DuplicateGroup
+	for _, f := range files {
+		if len(f.Links) == 0 || grouped[f.Path] {
+			continue
+		}
+		out = append(out, DuplicateGroup{GroupID: "linked-" + f.Path, Kind: MatchHardlink, Files: []FileInfo{f}})
+	}
+	return out
+}
+
+// Paths returns the file's path followed by the other hardlinks to the same inode.
+func (f FileInfo) Paths() []string {
+	return append([]string{f.Path}, f.Links...)
+}
+
+// reclaimable is the space freed by keeping one file of the group and removing the rest.
+// Each inode is counted once, so hardlinks never inflate the figure; linked and prefix groups
+// free nothing.
+func (g DuplicateGroup) reclaimable() int64 {
+	if g.Kind == MatchHardlink || g.Kind == MatchSymlink || g.Kind == MatchPrefix || len(g.Files) < 2 {
+		return 0
+	}
+	type key struct{ dev, ino uint64 }
+	seen := map[key]bool{}
+	var total, largest int64
+	for _, f := range g.Files {
+		if f.Inode != 0 {
+			k := key{f.Device, f.Inode}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		total += f.SizeBytes
+		if f.SizeBytes > largest {
+			largest = f.SizeBytes
+		}
+	}
+	return total - largest
+}
+
+// symlinkGroups reports each symbolic link with the path it points to. The target is resolved
+// relative to the link's directory; the group is informational and never acted upon.
+func symlinkGroups(links []FileInfo) []DuplicateGroup {
+	sort.Slice(links, func(i, j int) bool { return links[i].Path < links[j].Path })
+	out := make([]DuplicateGroup, 0, len(links))
+	for _, l := range links {
+		files := []FileInfo{l}
+		target := l.LinkTarget
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(l.Path), target)
+		}
+		if info, err := os.Stat(target); err == nil {
+			files = append(files, FileInfo{Path: target, SizeBytes: info.Size(), ModifiedUnix: info.ModTime().Unix()})
+		}
+		out = append(out, DuplicateGroup{GroupID: "symlink-" + l.Path, Kind: MatchSymlink, Files: files})
+	}
+	return out
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// diskTree writes files into a new temporary directory, which becomes the working directory
+// until the test ends, so scans of "." report the paths as written.
+func diskTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for p, data := range files {
+		p = filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chdir(t, dir)
+	return dir
+}
+
+func link(t *testing.T, oldname, newname string) {
+	t.Helper()
+	if err := os.Link(oldname, newname); err != nil {
+		t.Skipf("hardlinks unsupported: %v", err)
+	}
+}
+
+func TestHardlinksCollapse(t *testing.T) {
+	diskTree(t, map[string]string{"a": "same", "c": "same", "d": "solo"})
+	link(t, "a", "b")
+	link(t, "d", "e")
+	link(t, "d", "f")
+	groups, _, err := (ExactEngine{}).Scan(ScanConfig{IncludePaths: []string{"."}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGroups(t, groups, []string{"exact a c", "linked d"})
+	if len(groups) != 2 {
+		return
+	}
+	exact, linked := groups[0], groups[1]
+	if !equalStrings(exact.Files[0].Paths(), []string{"a", "b"}) || len(exact.Files[1].Links) != 0 {
+		t.Errorf("exact group paths %q and %q", exact.Files[0].Paths(), exact.Files[1].Paths())
+	}
+	if exact.ReclaimableBytes != 4 || exact.TotalBytes != 8 {
+		t.Errorf("exact group reclaims %d of %d bytes, want 4 of 8", exact.ReclaimableBytes, exact.TotalBytes)
+	}
+	if !equalStrings(linked.Files[0].Paths(), []string{"d", "e", "f"}) || linked.ReclaimableBytes != 0 {
+		t.Errorf("linked group paths %q, reclaims %d", linked.Files[0].Paths(), linked.ReclaimableBytes)
+	}
+}
+
+func TestCollapseHardlinks(t *testing.T) {
+	files := []FileInfo{
+		{Path: "a", Device: 1, Inode: 10},
+		{Path: "b", Device: 1, Inode: 10},
+		{Path: "c", Device: 2, Inode: 10}, // same inode on another device
+		{Path: "a", Device: 1, Inode: 10}, // seen through an overlapping root
+		{Path: "d"},
+		{Path: "e"},
+	}
+	got := collapseHardlinks(files)
+	var paths [][]string
+	for _, f := range got {
+		paths = append(paths, f.Paths())
+	}
+	want := [][]string{{"a", "b"}, {"c"}, {"d"}, {"e"}}
+	if len(paths) != len(want) {
+		t.Fatalf("collapsed to %q, want %q", paths, want)
+	}
+	for i := range want {
+		if !equalStrings(paths[i], want[i]) {
+			t.Errorf("collapsed to %q, want %q", paths, want)
+		}
+	}
+}
+
+func TestReclaimable(t *testing.T) {
+	tests := []struct {
+		name  string
+		group DuplicateGroup
+		want  int64
+	}{
+		{
+			name:  "copies",
+			group: DuplicateGroup{Kind: MatchExact, Files: []FileInfo{{SizeBytes: 5, Inode: 1}, {SizeBytes: 5, Inode: 2}, {SizeBytes: 5, Inode: 3}}},
+			want:  10,
+		},
+		{
+			name:  "one inode counted once",
+			group: DuplicateGroup{Kind: MatchExact, Files: []FileInfo{{SizeBytes: 5, Inode: 1}, {SizeBytes: 5, Inode: 1}, {SizeBytes: 5, Inode: 2}}},
+			want:  5,
+		},
+		{
+			name:  "unknown inodes",
+			group: DuplicateGroup{Kind: MatchExact, Files: []FileInfo{{SizeBytes: 5}, {SizeBytes: 5}}},
+			want:  5,
+		},
+		{
+			name:  "the largest is kept",
+			group: DuplicateGroup{Kind: MatchPerceptual, Files: []FileInfo{{SizeBytes: 3, Inode: 1}, {SizeBytes: 9, Inode: 2}}},
+			want:  3,
+		},
+		{
+			name:  "linked",
+			group: DuplicateGroup{Kind: MatchHardlink, Files: []FileInfo{{SizeBytes: 5, Inode: 1, Links: []string{"b"}}}},
+		},
+		{
+			name:  "prefix",
+			group: DuplicateGroup{Kind: MatchPrefix, Files: []FileInfo{{SizeBytes: 5, Inode: 1}, {SizeBytes: 5, Inode: 2}}},
+		},
+		{
+			name:  "symlink",
+			group: DuplicateGroup{Kind: MatchSymlink, Files: []FileInfo{{SizeBytes: 0}, {SizeBytes: 5}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.group.reclaimable(); got != tt.want {
+				t.Errorf("reclaimable = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultHashAlgorithm is used when ScanConfig.HashAlgorithm is empty.
+const DefaultHashAlgorithm = "sha256"
+
+// Hasher describes a content hash algorithm available to the scanner.
+type Hasher struct {
+	Name        string
+	Description string
+	// CollisionSafe reports whether equal digests are trusted as equal content before a
+	// destructive action. Groups found with unsafe algorithms are confirmed byte by byte.
+	CollisionSafe bool
+	New           func() hash.Hash
+}
+
+var (
+	hashersMu sync.RWMutex
+	hashers   = map[string]Hasher{}
+)
+
+// RegisterHasher adds or replaces a hash algorithm in the registry. It is safe to call while
+// scans run.
+func RegisterHasher(h Hasher) {
+	hashersMu.Lock()
+	defer hashersMu.Unlock()
+	hashers[strings.ToLower(h.Name)] = h
+}
+
+// LookupHasher resolves an algorithm by name; an empty name selects DefaultHashAlgorithm.
+func LookupHasher(name string) (Hasher, error) {
+	if name == "" {
+		name = DefaultHashAlgorithm
+	}
+	hashersMu.RLock()
+	h, ok := hashers[strings.ToLower(name)]
+	hashersMu.RUnlock()
+	if !ok {
+		return Hasher{}, fmt.Errorf("unknown hash algorithm %q (available: %s)", name, strings.Join(HasherNames(), ", "))
+	}
+	return h, nil
+}
+
+// HasherNames lists registered algorithm names in sorted order.
+func HasherNames() []string {
+	hashersMu.RLock()
+	names := make([]string, 0, len(hashers))
+	for name := range hashers {
+		names = append(names, name)
+	}
+	hashersMu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterHasher(Hasher{Name: "sha1", Description: "SHA-1", New: sha1.New})
+	RegisterHasher(Hasher{Name: "sha256", Description: "SHA-256", CollisionSafe: true, New: sha256.New})
+	RegisterHasher(Hasher{Name: "md5", Description: "MD5", New: md5.New})
+	RegisterHasher(Hasher{Name: "xxh64", Description: "xxHash64, fast non-cryptographic", New: func() hash.Hash { return newXXH64() }})
+	RegisterHasher(Hasher{Name: "sha256-tree", Description: "SHA-256 Merkle tree over 1 MiB chunks, hashed in sequence", CollisionSafe: true, New: newTreeHash})
+}
+
+// sampleBytes is the size of the head and tail windows read by readSample.
+const sampleBytes int64 = 64 << 10
+
+// readSample returns the first and last sampleBytes of a file.
+// Files no larger than two windows are returned in full, so their sample hash is already final.
+func readSample(src source, path string, size int64) ([]byte, error) {
+	f, err := src.open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if size <= 2*sampleBytes {
+		return io.ReadAll(f)
+	}
+	buf := make([]byte, 2*sampleBytes)
+	if _, err := io.ReadFull(f, buf[:sampleBytes]); err != nil {
+		return nil, err
+	}
+	if ra, ok := f.(io.ReaderAt); ok {
+		_, err = ra.ReadAt(buf[sampleBytes:], size-sampleBytes)
+	} else if _, err = io.CopyN(io.Discard, f, size-2*sampleBytes); err == nil {
+		_, err = io.ReadFull(f, buf[sampleBytes:]) // no random access: read through to the tail
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func hexSum(h hash.Hash) string { return hex.EncodeToString(h.Sum(nil)) }
+
+// hashPath computes the full hash of a file outside of a scan.
+func hashPath(alg Hasher, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := alg.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hexSum(h), nil
+}
+
+// sameContent compares two files, or archive members, byte by byte.
+func sameContent(src source, a, b FileInfo) (bool, error) {
+	fa, err := src.openContent(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := src.openContent(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+	bufA := make([]byte, 32<<10)
+	bufB := make([]byte, 32<<10)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !endA {
+			return false, errA
+		}
+		if errB != nil && !endB {
+			return false, errB
+		}
+		if endA || endB {
+			return endA == endB, nil
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestXXH64(t *testing.T) {
+	// reference values of xxHash64 with seed 0
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "ef46db3751d8e999"},
+		{"a", "d24ec4f1a98c6e5b"},
+		{"abc", "44bc2cf5ad770999"},
+		{"Nobody inspects the spammish repetition", "fbcea83c8a378bf1"},
+		{"The quick brown fox jumps over the lazy dog", "0b242d361fda71bc"},
+	}
+	for _, tt := range tests {
+		h := newXXH64()
+		h.Write([]byte(tt.in))
+		if got := hexSum(h); got != tt.want {
+			t.Errorf("xxh64(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+		// the same input written a byte at a time
+		h.Reset()
+		for i := 0; i < len(tt.in); i++ {
+			h.Write([]byte{tt.in[i]})
+		}
+		if got := hexSum(h); got != tt.want {
+			t.Errorf("xxh64(%q) in single bytes = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTreeHash(t *testing.T) {
+	leaf := func(index byte, data []byte) []byte {
+		h := sha256.New()
+		h.Write([]byte{0, index, 0, 0, 0, 0, 0, 0, 0})
+		h.Write(data)
+		return h.Sum(nil)
+	}
+	parent := func(left, right []byte) []byte {
+		h := sha256.New()
+		h.Write([]byte{1})
+		h.Write(left)
+		h.Write(right)
+		return h.Sum(nil)
+	}
+	chunk := func(b byte) []byte { return bytes.Repeat([]byte{b}, treeChunkSize) }
+	a, b, c := chunk('a'), chunk('b'), chunk('c')
+	tests := []struct {
+		name string
+		in   []byte
+		want []byte
+	}{
+		{"empty", nil, leaf(0, nil)},
+		{"short", []byte("abc"), leaf(0, []byte("abc"))},
+		{"one chunk", a, leaf(0, a)},
+		{"two chunks", append(append([]byte{}, a...), b...), parent(leaf(0, a), leaf(1, b))},
+		{
+			"three chunks and a bit",
+			append(append(append(append([]byte{}, a...), b...), c...), 'd'),
+			parent(parent(leaf(0, a), leaf(1, b)), parent(leaf(2, c), leaf(3, []byte("d")))),
+		},
+		{
+			"three chunks",
+			append(append(append([]byte{}, a...), b...), c...),
+			parent(parent(leaf(0, a), leaf(1, b)), leaf(2, c)),
+		},
+	}
+	alg, err := LookupHasher("sha256-tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		want := hex.EncodeToString(tt.want)
+		h := alg.New()
+		h.Write(tt.in)
+		if got := hexSum(h); got != want {
+			t.Errorf("%s: %s, want %s", tt.name, got, want)
+		}
+		// odd write sizes cross chunk boundaries mid-write
+		h.Reset()
+		for in := tt.in; len(in) > 0; {
+			n := 77777
+			if n > len(in) {
+				n = len(in)
+			}
+			h.Write(in[:n])
+			in = in[n:]
+		}
+		if got := hexSum(h); got != want {
+			t.Errorf("%s in pieces: %s, want %s", tt.name, got, want)
+		}
+	}
+}
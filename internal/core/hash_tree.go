@@ -0,0 +1,94 @@
+package core
+
+import (
+	"crypto/sha256"
+	"hash"
+)
+
+// treeChunkSize is the leaf size of the tree hash.
+const treeChunkSize = 1 << 20
+
+// treeHash is a binary Merkle tree over fixed-size chunks, shaped like BLAKE3's, using SHA-256
+// as the compression function. Leaves and parents are domain separated and every chunk carries
+// its index, so reordered chunks never collide. Chunks are hashed one after another as they are
+// written; like BLAKE3, completed subtrees are merged eagerly, keeping at most log2(chunks) roots.
+type treeHash struct {
+	stack  [][sha256.Size]byte
+	buf    []byte
+	chunks uint64
+}
+
+func newTreeHash() hash.Hash {
+	return &treeHash{buf: make([]byte, 0, treeChunkSize)}
+}
+
+func (t *treeHash) Reset() {
+	t.stack = t.stack[:0]
+	t.buf = t.buf[:0]
+	t.chunks = 0
+}
+
+func (t *treeHash) Size() int      { return sha256.Size }
+func (t *treeHash) BlockSize() int { return sha256.BlockSize }
+
+func (t *treeHash) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if len(t.buf) == treeChunkSize {
+			// only flush once more data arrives, so the final chunk is known at Sum time
+			t.pushChunk()
+		}
+		c := treeChunkSize - len(t.buf)
+		if c > len(p) {
+			c = len(p)
+		}
+		t.buf = append(t.buf, p[:c]...)
+		p = p[c:]
+	}
+	return n, nil
+}
+
+func (t *treeHash) pushChunk() {
+	leaf := treeLeaf(t.chunks, t.buf)
+	t.buf = t.buf[:0]
+	t.chunks++
+	t.stack = append(t.stack, leaf)
+	// merge one pair for every trailing zero bit of the chunk count (BLAKE3 lazy merging)
+	for c := t.chunks; c&1 == 0; c >>= 1 {
+		n := len(t.stack)
+		t.stack = append(t.stack[:n-2], treeParent(t.stack[n-2], t.stack[n-1]))
+	}
+}
+
+func (t *treeHash) Sum(b []byte) []byte {
+	// fold a copy so Sum does not disturb further writes
+	root := treeLeaf(t.chunks, t.buf)
+	for i := len(t.stack) - 1; i >= 0; i-- {
+		root = treeParent(t.stack[i], root)
+	}
+	return append(b, root[:]...)
+}
+
+func treeLeaf(index uint64, data []byte) [sha256.Size]byte {
+	h := sha256.New()
+	var hdr [9]byte
+	hdr[0] = 0
+	for i := 0; i < 8; i++ {
+		hdr[1+i] = byte(index >> (8 * uint(i)))
+	}
+	h.Write(hdr[:])
+	h.Write(data)
+	var out [sha256.Size]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+func treeParent(left, right [sha256.Size]byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left[:])
+	h.Write(right[:])
+	var out [sha256.Size]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// xxHash64 (seed 0) as specified at https://github.com/Cyan4973/xxHash.
+// Fast but not collision resistant, so groups found with it are confirmed byte by byte.
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+type xxh64 struct {
+	v1, v2, v3, v4 uint64
+	total          uint64
+	mem            [32]byte
+	n              int // bytes buffered in mem
+}
+
+func newXXH64() hash.Hash64 {
+	d := &xxh64{}
+	d.Reset()
+	return d
+}
+
+func (d *xxh64) Reset() {
+	d.v1 = xxPrime1
+	d.v1 += xxPrime2
+	d.v2 = xxPrime2
+	d.v3 = 0
+	d.v4 = 0
+	d.v4 -= xxPrime1
+	d.total = 0
+	d.n = 0
+}
+
+func (d *xxh64) Size() int      { return 8 }
+func (d *xxh64) BlockSize() int { return 32 }
+
+func (d *xxh64) Write(p []byte) (int, error) {
+	n := len(p)
+	d.total += uint64(n)
+	if d.n+len(p) < 32 {
+		d.n += copy(d.mem[d.n:], p)
+		return n, nil
+	}
+	if d.n > 0 {
+		c := copy(d.mem[d.n:], p)
+		p = p[c:]
+		d.v1 = xxRound(d.v1, binary.LittleEndian.Uint64(d.mem[0:8]))
+		d.v2 = xxRound(d.v2, binary.LittleEndian.Uint64(d.mem[8:16]))
+		d.v3 = xxRound(d.v3, binary.LittleEndian.Uint64(d.mem[16:24]))
+		d.v4 = xxRound(d.v4, binary.LittleEndian.Uint64(d.mem[24:32]))
+		d.n = 0
+	}
+	for len(p) >= 32 {
+		d.v1 = xxRound(d.v1, binary.LittleEndian.Uint64(p[0:8]))
+		d.v2 = xxRound(d.v2, binary.LittleEndian.Uint64(p[8:16]))
+		d.v3 = xxRound(d.v3, binary.LittleEndian.Uint64(p[16:24]))
+		d.v4 = xxRound(d.v4, binary.LittleEndian.Uint64(p[24:32]))
+		p = p[32:]
+	}
+	d.n = copy(d.mem[:], p)
+	return n, nil
+}
+
+func (d *xxh64) Sum64() uint64 {
+	var h uint64
+	if d.total >= 32 {
+		h = bits.RotateLeft64(d.v1, 1) + bits.RotateLeft64(d.v2, 7) +
+			bits.RotateLeft64(d.v3, 12) + bits.RotateLeft64(d.v4, 18)
+		h = xxMerge(h, d.v1)
+		h = xxMerge(h, d.v2)
+		h = xxMerge(h, d.v3)
+		h = xxMerge(h, d.v4)
+	} else {
+		h = xxPrime5
+	}
+	h += d.total
+	p := d.mem[:d.n]
+	for len(p) >= 8 {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(p))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+		p = p[8:]
+	}
+	if len(p) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(p)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		p = p[4:]
+	}
+	for _, b := range p {
+		h ^= uint64(b) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+func (d *xxh64) Sum(b []byte) []byte {
+	var out [8]byte
+	binary.BigEndian.PutUint64(out[:], d.Sum64())
+	return append(b, out[:]...)
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMerge(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*xxPrime1 + xxPrime4
+}
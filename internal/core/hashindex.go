@@ -0,0 +1,408 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// IndexEntry is the cached hashing state of one file. It is valid while path, size,
+// modification time and inode are unchanged. Content hashes are bound to Algorithm.
+type IndexEntry struct {
+	Path                string // absolute, see indexKey
+	SizeBytes           int64
+	ModNano             int64
+	Inode               uint64
+	Algorithm           string    `json:",omitempty"`
+	Sample              string    `json:",omitempty"` // head/tail sample hash
+	Full                string    `json:",omitempty"` // full content hash
+	Perceptual          string    `json:",omitempty"` // perceptual hash for image/video modes
+	PerceptualAlgorithm string    `json:",omitempty"` // perceptual hasher that produced Perceptual
+	Type                string    `json:",omitempty"` // sniffed MIME type
+	Class               FileClass `json:",omitempty"` // class of Type
+	SeenUnix            int64
+	Deleted             bool `json:",omitempty"` // tombstone in the log
+}
+
+// HashIndex is a persistent file hash cache so that unchanged files are not re-read on rescans.
+// It is stored as an append-only JSON-lines log: every update appends one record, the latest record
+// for a path wins, and Compact rewrites the file with only live entries.
+type HashIndex struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]IndexEntry
+	dirty   map[string]bool
+	records int // records in the log file, live or not
+}
+
+// HashIndexStats summarizes the content of a HashIndex.
+type HashIndexStats struct {
+	Path        string
+	Entries     int
+	LogRecords  int
+	FileBytes   int64
+	ByAlgorithm map[string]int
+	Perceptual  int
+}
+
+// HashIndexVerifyResult lists entries whose stored hash no longer matches the file, and those
+// that could not be checked.
+type HashIndexVerifyResult struct {
+	Checked    int
+	Stale      []string // file changed or vanished; entry dropped
+	Mismatched []string // metadata unchanged but content differs; entry dropped
+	Unreadable []string // could not be read to check; entry kept
+}
+
+// DefaultHashIndexPath returns the default location of the hash index in the user cache dir.
+func DefaultHashIndexPath() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "haste", "hashindex.jsonl")
+}
+
+// OpenHashIndex loads the index at path, creating an empty one if the file does not exist.
+func OpenHashIndex(path string) (*HashIndex, error) {
+	idx := &HashIndex{path: path, entries: map[string]IndexEntry{}, dirty: map[string]bool{}}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+	for sc.Scan() {
+		var e IndexEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue // torn write at the end of the log
+		}
+		idx.records++
+		if e.Deleted {
+			delete(idx.entries, e.Path)
+			continue
+		}
+		idx.entries[e.Path] = e
+	}
+	return idx, sc.Err()
+}
+
+// Path returns the file backing the index.
+func (x *HashIndex) Path() string { return x.path }
+
+// lookup returns the entry for f if its metadata still matches. Archive members are not indexed:
+// their metadata comes from the archive and Prune/Verify could not stat them.
+func (x *HashIndex) lookup(f FileInfo) (IndexEntry, bool) {
+	if x == nil || f.Archive != "" {
+		return IndexEntry{}, false
+	}
+	key := indexKey(f.Path)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	e, ok := x.entries[key]
+	if !ok || e.SizeBytes != f.SizeBytes || e.ModNano != f.modNano || e.Inode != f.Inode {
+		return IndexEntry{}, false
+	}
+	return e, true
+}
+
+// indexKey is the path an entry is stored under: absolute and clean, so a file scanned through
+// a relative path is found again, and checked by Prune and Verify, from any directory.
+func indexKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// contentHash returns a cached hash of f for algorithm alg; full selects the full hash over the sample.
+func (x *HashIndex) contentHash(f FileInfo, alg string, full bool) (string, bool) {
+	e, ok := x.lookup(f)
+	if !ok || e.Algorithm != alg {
+		return "", false
+	}
+	if full {
+		return e.Full, e.Full != ""
+	}
+	return e.Sample, e.Sample != ""
+}
+
+// update applies fn to the current entry of f, resetting it first if the file changed.
+func (x *HashIndex) update(f FileInfo, fn func(e *IndexEntry)) {
+	if x == nil || f.Archive != "" {
+		return
+	}
+	key := indexKey(f.Path)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	e, ok := x.entries[key]
+	if !ok || e.SizeBytes != f.SizeBytes || e.ModNano != f.modNano || e.Inode != f.Inode {
+		e = IndexEntry{Path: key, SizeBytes: f.SizeBytes, ModNano: f.modNano, Inode: f.Inode}
+	}
+	fn(&e)
+	e.SeenUnix = time.Now().Unix()
+	x.entries[key] = e
+	x.dirty[key] = true
+}
+
+// putContentHash stores a sample or full hash. Hashes of another algorithm are discarded.
+func (x *HashIndex) putContentHash(f FileInfo, alg string, full bool, h string) {
+	x.update(f, func(e *IndexEntry) {
+		if e.Algorithm != alg {
+			e.Algorithm, e.Sample, e.Full = alg, "", ""
+		}
+		if full {
+			e.Full = h
+		} else {
+			e.Sample = h
+		}
+	})
+}
+
+// perceptualHash returns a cached perceptual hash of f made by algorithm alg.
+func (x *HashIndex) perceptualHash(f FileInfo, alg string) (string, bool) {
+	e, ok := x.lookup(f)
+	return e.Perceptual, ok && e.Perceptual != "" && e.PerceptualAlgorithm == alg
+}
+
+func (x *HashIndex) putPerceptualHash(f FileInfo, alg string, h string) {
+	x.update(f, func(e *IndexEntry) { e.Perceptual, e.PerceptualAlgorithm = h, alg })
+}
+
+func (x *HashIndex) fileType(f FileInfo) (string, FileClass, bool) {
+	e, ok := x.lookup(f)
+	return e.Type, e.Class, ok && e.Type != ""
+}
+
+func (x *HashIndex) putFileType(f FileInfo, mime string, class FileClass) {
+	x.update(f, func(e *IndexEntry) { e.Type, e.Class = mime, class })
+}
+
+// Flush appends all entries changed since the last flush to the log.
+func (x *HashIndex) Flush() error {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if len(x.dirty) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(x.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(x.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, p := range sortedKeys(x.dirty) {
+		e, ok := x.entries[p]
+		if !ok {
+			e = IndexEntry{Path: p, Deleted: true}
+		}
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+		x.records++
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	x.dirty = map[string]bool{}
+	return f.Close()
+}
+
+// hashIndexFlushInterval is how often a running scan appends new hashes to the log, so a scan
+// that dies keeps most of its work.
+const hashIndexFlushInterval = 10 * time.Second
+
+// flushEvery flushes x every interval until stop is called, and once more then. Failures are
+// passed to fail.
+func (x *HashIndex) flushEvery(interval time.Duration, fail func(error)) (stop func()) {
+	if x == nil {
+		return func() {}
+	}
+	quit := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-t.C:
+				if err := x.Flush(); err != nil {
+					fail(err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		wg.Wait()
+		if err := x.Flush(); err != nil {
+			fail(err)
+		}
+	}
+}
+
+// Compact rewrites the log with only live entries.
+func (x *HashIndex) Compact() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(x.path), 0o755); err != nil {
+		return err
+	}
+	tmp := x.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, p := range sortedKeys(x.entries) {
+		if err := enc.Encode(x.entries[p]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, x.path); err != nil {
+		return err
+	}
+	x.records = len(x.entries)
+	x.dirty = map[string]bool{}
+	return nil
+}
+
+// Stats reports entry counts and the on-disk size of the index.
+func (x *HashIndex) Stats() HashIndexStats {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	st := HashIndexStats{Path: x.path, Entries: len(x.entries), LogRecords: x.records, ByAlgorithm: map[string]int{}}
+	for _, e := range x.entries {
+		if e.Algorithm != "" {
+			st.ByAlgorithm[e.Algorithm]++
+		}
+		if e.Perceptual != "" {
+			st.Perceptual++
+		}
+	}
+	if fi, err := os.Stat(x.path); err == nil {
+		st.FileBytes = fi.Size()
+	}
+	return st
+}
+
+// Prune drops entries whose file is gone or changed, and entries not seen for olderThan
+// (0 keeps them regardless of age). The index is compacted afterwards.
+func (x *HashIndex) Prune(olderThan time.Duration) (int, error) {
+	x.mu.Lock()
+	paths := sortedKeys(x.entries)
+	x.mu.Unlock()
+	cutoff := int64(0)
+	if olderThan > 0 {
+		cutoff = time.Now().Add(-olderThan).Unix()
+	}
+	removed := 0
+	for _, p := range paths {
+		x.mu.Lock()
+		e := x.entries[p]
+		x.mu.Unlock()
+		if (cutoff > 0 && e.SeenUnix < cutoff) || !x.entryCurrent(e) {
+			x.mu.Lock()
+			delete(x.entries, p)
+			x.mu.Unlock()
+			removed++
+		}
+	}
+	return removed, x.Compact()
+}
+
+// Verify re-hashes every entry with a full hash and drops entries that are stale or whose
+// content no longer matches, e.g. after silent corruption or a tool that preserved mtimes.
+// Entries whose file cannot be read are kept and listed as Unreadable.
+func (x *HashIndex) Verify() (HashIndexVerifyResult, error) {
+	var res HashIndexVerifyResult
+	x.mu.Lock()
+	paths := sortedKeys(x.entries)
+	x.mu.Unlock()
+	for _, p := range paths {
+		x.mu.Lock()
+		e := x.entries[p]
+		x.mu.Unlock()
+		if e.Full == "" {
+			continue
+		}
+		res.Checked++
+		if !x.entryCurrent(e) {
+			res.Stale = append(res.Stale, p)
+			x.drop(p)
+			continue
+		}
+		alg, err := LookupHasher(e.Algorithm)
+		if err != nil {
+			res.Stale = append(res.Stale, p)
+			x.drop(p)
+			continue
+		}
+		h, err := hashPath(alg, p)
+		if err != nil {
+			res.Unreadable = append(res.Unreadable, p)
+			continue
+		}
+		if h != e.Full {
+			res.Mismatched = append(res.Mismatched, p)
+			x.drop(p)
+		}
+	}
+	return res, x.Flush()
+}
+
+func (x *HashIndex) drop(path string) {
+	x.mu.Lock()
+	delete(x.entries, path)
+	x.dirty[path] = true
+	x.mu.Unlock()
+}
+
+// entryCurrent reports whether the file behind e still has the recorded metadata.
+func (x *HashIndex) entryCurrent(e IndexEntry) bool {
+	info, err := os.Stat(e.Path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	_, ino := fileIdentity(e.Path, info)
+	return info.Size() == e.SizeBytes && info.ModTime().UnixNano() == e.ModNano && ino == e.Inode
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// chdir changes the working directory until the test ends.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	old, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(old) })
+}
+
+// diskFile describes path as the walk would.
+func diskFile(t *testing.T, path string) FileInfo {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev, ino := fileIdentity(path, info)
+	return FileInfo{Path: path, SizeBytes: info.Size(), Device: dev, Inode: ino, modNano: info.ModTime().UnixNano()}
+}
+
+func TestHashIndexRoundTrip(t *testing.T) {
+	dir, elsewhere := t.TempDir(), t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("same content"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	indexPath := filepath.Join(elsewhere, "index.jsonl")
+	idx, err := OpenHashIndex(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a scan through a relative path, then one through the absolute path, share their entries
+	chdir(t, dir)
+	for _, root := range []string{".", dir} {
+		if _, _, err := (ExactEngine{}).Scan(ScanConfig{IncludePaths: []string{root}, HashIndex: idx}); err != nil {
+			t.Fatal(err)
+		}
+		if n := idx.Stats().Entries; n != 2 {
+			t.Fatalf("scanning %s: %d entries, want 2", root, n)
+		}
+	}
+
+	// reopened from another directory, the entries are found by any path to the files
+	chdir(t, elsewhere)
+	idx, err = OpenHashIndex(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, err := filepath.Rel(elsewhere, filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{filepath.Join(dir, "a"), rel} {
+		if _, ok := idx.contentHash(diskFile(t, p), DefaultHashAlgorithm, true); !ok {
+			t.Errorf("no hash for %s", p)
+		}
+	}
+	if n, err := idx.Prune(0); err != nil || n != 0 {
+		t.Errorf("Prune removed %d entries (%v), want 0", n, err)
+	}
+	res, err := idx.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Checked != 2 || len(res.Stale)+len(res.Mismatched)+len(res.Unreadable) != 0 {
+		t.Errorf("Verify: %+v", res)
+	}
+
+	// a changed file loses its entry
+	a := filepath.Join(dir, "a")
+	if err := os.WriteFile(a, []byte("other content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(a, time.Now(), time.Now().Add(time.Hour))
+	if n, err := idx.Prune(0); err != nil || n != 1 {
+		t.Errorf("Prune removed %d entries (%v), want 1", n, err)
+	}
+	idx, err = OpenHashIndex(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := idx.Stats().Entries; n != 1 {
+		t.Errorf("%d entries after Prune, want 1", n)
+	}
+}
+
+func TestHashIndexVerifyFindsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "f")
+	if err := os.WriteFile(p, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := OpenHashIndex(filepath.Join(dir, "index.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := diskFile(t, p)
+	idx.putContentHash(f, DefaultHashAlgorithm, true, "not the hash")
+	res, err := idx.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Mismatched) != 1 || res.Mismatched[0] != p {
+		t.Errorf("Verify: %+v", res)
+	}
+	if _, ok := idx.contentHash(f, DefaultHashAlgorithm, true); ok {
+		t.Error("the mismatched entry was kept")
+	}
+}
@@ -0,0 +1,285 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// IgnoreFileName is the per-directory rule file read during the walk. Its rules apply to the
+// directory that contains it and everything below, like a .gitignore.
+const IgnoreFileName = ".hasteignore"
+
+// PathRules is an ordered list of gitignore-style patterns:
+//
+//   - blank lines and lines starting with # are skipped
+//   - a leading ! negates the pattern; the last matching pattern decides
+//   - a trailing / matches directories only
+//   - a pattern without a / in the middle or at the start matches a name at any depth;
+//     otherwise it is anchored to the directory the rules belong to
+//   - * and ? never cross a /, [...] matches a class, ** matches any number of directories
+//
+// For compatibility with earlier exclude lists, an absolute pattern is also tried against
+// the full path.
+type PathRules struct {
+	rules []pathRule
+}
+
+type pathRule struct {
+	segs     []string // pattern split on /, a leading ** for unanchored patterns
+	negate   bool
+	dirOnly  bool
+	absolute bool // anchored pattern that is also an absolute path
+}
+
+// ParsePathRules compiles patterns. Invalid patterns are reported in the error and left out;
+// the rules that did compile are returned either way.
+func ParsePathRules(patterns []string) (*PathRules, error) {
+	r := &PathRules{}
+	var errs []error
+	for _, line := range patterns {
+		rule, ok, err := parsePathRule(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%q: %w", line, err))
+			continue
+		}
+		if ok {
+			r.rules = append(r.rules, rule)
+		}
+	}
+	return r, errors.Join(errs...)
+}
+
+func parsePathRule(line string) (pathRule, bool, error) {
+	var rule pathRule
+	p := strings.TrimRight(line, " \t\r")
+	if strings.HasSuffix(p, "\\") && len(line) > len(p) {
+		p += " " // escaped trailing space
+	}
+	p = strings.TrimLeft(p, " \t")
+	if p == "" || strings.HasPrefix(p, "#") {
+		return rule, false, nil
+	}
+	if filepath.Separator == '\\' {
+		// Windows users write paths with backslashes, so they are separators rather than escapes
+		p = strings.ReplaceAll(p, "\\", "/")
+	}
+	switch {
+	case strings.HasPrefix(p, "!"):
+		rule.negate = true
+		p = p[1:]
+	case strings.HasPrefix(p, "\\!"), strings.HasPrefix(p, "\\#"):
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		rule.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if p == "" {
+		return rule, false, nil
+	}
+	anchored := strings.Contains(p, "/")
+	rule.absolute = filepath.IsAbs(filepath.FromSlash(p))
+	p = strings.TrimPrefix(p, "/")
+	for _, s := range strings.Split(p, "/") {
+		if s == "" {
+			continue
+		}
+		if strings.Contains(s, "**") && s != "**" {
+			s = strings.ReplaceAll(s, "**", "*") // ** inside a name is a plain *
+		}
+		if _, err := path.Match(s, ""); err != nil {
+			return rule, false, err
+		}
+		rule.segs = append(rule.segs, s)
+	}
+	if !anchored {
+		rule.segs = append([]string{"**"}, rule.segs...)
+	}
+	return rule, true, nil
+}
+
+// Len returns the number of compiled rules.
+func (r *PathRules) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.rules)
+}
+
+// Match checks rel, a /-separated path relative to the directory the rules belong to.
+// matched reports whether any rule applies; positive whether the deciding rule is not negated.
+func (r *PathRules) Match(rel string, isDir bool) (positive, matched bool) {
+	return r.match(rel, "", isDir)
+}
+
+// match is Match that also tries absolute rules against full, the path in / form.
+func (r *PathRules) match(rel, full string, isDir bool) (positive, matched bool) {
+	if r == nil {
+		return false, false
+	}
+	name := splitPath(rel)
+	var abs []string
+	for i := len(r.rules) - 1; i >= 0; i-- {
+		rule := r.rules[i]
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		hit := matchSegments(rule.segs, name)
+		if !hit && rule.absolute && full != "" {
+			if abs == nil {
+				abs = splitPath(full)
+			}
+			hit = matchSegments(rule.segs, abs)
+		}
+		if hit {
+			return !rule.negate, true
+		}
+	}
+	return false, false
+}
+
+func splitPath(p string) []string {
+	var out []string
+	for _, s := range strings.Split(p, "/") {
+		if s != "" && s != "." {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// matchSegments matches path segments against pattern segments, where ** spans zero or
+// more segments. A trailing ** needs at least one segment: "dir/**" matches inside dir only.
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			rest := pat[1:]
+			if len(rest) == 0 {
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// LoadPathRules reads a rule file such as .hasteignore.
+func LoadPathRules(file string) (*PathRules, error) {
+	return loadPathRules(source{}, file)
+}
+
+func loadPathRules(src source, file string) (*PathRules, error) {
+	f, err := src.open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return ParsePathRules(lines)
+}
+
+// walkRules decides what the walk visits. Exclude rules from the config take precedence over
+// .hasteignore files, and a deeper .hasteignore over the ones above it. An excluded directory
+// is pruned, so nothing below it can be re-included, as with git. Include rules, when given,
+// select which files are kept; they never prune directories.
+type walkRules struct {
+	exclude *PathRules
+	include *PathRules
+	rep     *ScanReport
+	src     source
+
+	mu   sync.Mutex
+	dirs map[string]*PathRules // directory -> its .hasteignore rules, only for directories that have one
+}
+
+func newWalkRules(config ScanConfig, rep *ScanReport) (*walkRules, error) {
+	exclude, err := ParsePathRules(config.ExcludePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("exclude patterns: %w", err)
+	}
+	include, err := ParsePathRules(config.IncludePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("include patterns: %w", err)
+	}
+	return &walkRules{exclude: exclude, include: include, rep: rep, src: source{fsys: config.FS}, dirs: map[string]*PathRules{}}, nil
+}
+
+// enter loads the .hasteignore of dir, if any, before its entries are visited.
+func (w *walkRules) enter(dir string) {
+	file := filepath.Join(dir, IgnoreFileName)
+	rules, err := loadPathRules(w.src, file)
+	switch {
+	case rules == nil && !errors.Is(err, os.ErrNotExist):
+		w.rep.addIO(file, "walking", err)
+	case rules != nil && err != nil:
+		w.rep.add(file, "walking", ErrorDecode, err) // bad lines are skipped, the rest apply
+	}
+	if rules.Len() == 0 {
+		return
+	}
+	w.mu.Lock()
+	w.dirs[dir] = rules
+	w.mu.Unlock()
+}
+
+// excluded reports whether p, found under root, is left out of the scan.
+func (w *walkRules) excluded(root, p string, isDir bool) bool {
+	rel, err := filepath.Rel(root, p)
+	if err != nil || rel == "." {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	full := filepath.ToSlash(p)
+	if positive, ok := w.exclude.match(rel, full, isDir); ok {
+		return positive
+	}
+	// .hasteignore files from the parent directory up to the root, deepest first
+	dir := filepath.Dir(p)
+	for {
+		w.mu.Lock()
+		rules := w.dirs[dir]
+		w.mu.Unlock()
+		if rules != nil {
+			sub, err := filepath.Rel(dir, p)
+			if err == nil {
+				if positive, ok := rules.match(filepath.ToSlash(sub), full, isDir); ok {
+					return positive
+				}
+			}
+		}
+		if dir == root || dir == filepath.Dir(dir) {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	if isDir || w.include.Len() == 0 {
+		return false
+	}
+	positive, _ := w.include.match(rel, full, false)
+	return !positive
+}
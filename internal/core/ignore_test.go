@@ -0,0 +1,66 @@
+package core
+
+import "testing"
+
+func TestPathRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		dir      bool
+		positive bool
+		matched  bool
+	}{
+		{"no rules", nil, "a.txt", false, false, false},
+		{"comment and blank", []string{"# *.txt", ""}, "a.txt", false, false, false},
+		{"name at any depth", []string{"*.tmp"}, "x/y/a.tmp", false, true, true},
+		{"star stays in a segment", []string{"a/*.tmp"}, "a/b/c.tmp", false, false, false},
+		{"anchored by a slash", []string{"/build"}, "src/build", true, false, false},
+		{"anchored at the root", []string{"/build"}, "build", true, true, true},
+		{"anchored by an inner slash", []string{"doc/out"}, "x/doc/out", true, false, false},
+		{"double star", []string{"a/**/z"}, "a/b/c/z", false, true, true},
+		{"double star matches nothing", []string{"a/**/z"}, "a/z", false, true, true},
+		{"double star inside a name", []string{"a**b"}, "axxb", false, true, true},
+		{"directory only skips files", []string{"cache/"}, "cache", false, false, false},
+		{"directory only", []string{"cache/"}, "x/cache", true, true, true},
+		{"negation", []string{"*.log", "!keep.log"}, "keep.log", false, false, true},
+		{"last rule wins", []string{"!keep.log", "*.log"}, "keep.log", false, true, true},
+		{"class", []string{"[ab].txt"}, "b.txt", false, true, true},
+		{"question mark", []string{"?.txt"}, "ab.txt", false, false, false},
+		{"escaped bang", []string{`\!x`}, "!x", false, true, true},
+		{"escaped hash", []string{`\#x`}, "#x", false, true, true},
+	}
+	for _, tt := range tests {
+		r, err := ParsePathRules(tt.patterns)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		positive, matched := r.Match(tt.path, tt.dir)
+		if positive != tt.positive || matched != tt.matched {
+			t.Errorf("%s: Match(%q) = %v, %v, want %v, %v", tt.name, tt.path, positive, matched, tt.positive, tt.matched)
+		}
+	}
+}
+
+func TestParsePathRulesKeepsValidRules(t *testing.T) {
+	r, err := ParsePathRules([]string{"[", "*.tmp"})
+	if err == nil {
+		t.Error("no error for an unterminated class")
+	}
+	if r.Len() != 1 {
+		t.Errorf("%d rules compiled, want 1", r.Len())
+	}
+}
+
+func TestExcludePatternsDuringTheWalk(t *testing.T) {
+	fsys := textFS(map[string]string{
+		"keep.txt": "", "a.tmp": "", "sub/b.tmp": "", "sub/keep.tmp": "",
+		"build/c.txt": "", "src/build/d.txt": "",
+		"x/.hasteignore": "*.txt\n", "x/e.txt": "", "x/f.bin": "",
+	})
+	r := walkFS(t, fsys, ScanConfig{ExcludePatterns: []string{"*.tmp", "!keep.tmp", "/build/"}}, walkOptions{})
+	want := []string{"keep.txt", "src/build/d.txt", "sub/keep.tmp", "x/f.bin"}
+	if got := walked(r); !equalStrings(got, want) {
+		t.Errorf("walked %q, want %q", got, want)
+	}
+}
@@ -2,22 +2,30 @@ package core
 
 import (
 	"image"
+	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
-	"os"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
 )
 
 // GenerateImageThumbnail decodes an image file and returns a small RGBA image thumbnail (max 160px)
 func GenerateImageThumbnail(path string, maxSide int) (image.Image, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	img, _, err := image.Decode(f)
+	return imageThumbnail(source{}, path, maxSide)
+}
+
+func imageThumbnail(src source, path string, maxSide int) (image.Image, error) {
+	img, err := decodeImage(src, path)
 	if err != nil {
 		return nil, err
 	}
+	return shrink(img, maxSide), nil
+}
+
+// shrink scales img down so its longer side is at most maxSide pixels.
+func shrink(img image.Image, maxSide int) image.Image {
 	b := img.Bounds()
 	w := b.Dx()
 	h := b.Dy()
@@ -27,7 +35,7 @@ func GenerateImageThumbnail(path string, maxSide int) (image.Image, error) {
 		scale = float64(maxSide) / float64(h)
 	}
 	if scale >= 1 {
-		return img, nil
+		return img
 	}
 	nw := int(float64(w) * scale)
 	nh := int(float64(h) * scale)
@@ -37,46 +45,27 @@ func GenerateImageThumbnail(path string, maxSide int) (image.Image, error) {
 		for x := 0; x < nw; x++ {
 			sx := int(float64(x) / float64(nw) * float64(w))
 			sy := int(float64(y) / float64(nh) * float64(h))
-			out.Set(x, y, img.At(sx, sy))
+			out.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
 		}
 	}
-	return out, nil
+	return out
 }
 
-// PerceptualHash computes a simple average hash (aHash) 8x8 -> 64-bit string hex
-func PerceptualHash(img image.Image) string {
-	// downscale to 8x8 gray and compute average
-	w, h := 8, 8
-	total := uint64(0)
-	vals := make([]uint8, 0, 64)
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			// sample center of cell
-			px := (x*img.Bounds().Dx() + img.Bounds().Dx()/16) / w
-			py := (y*img.Bounds().Dy() + img.Bounds().Dy()/16) / h
-			r, g, b, _ := img.At(px, py).RGBA()
-			g8 := uint8((r*299 + g*587 + b*114) / 1000 >> 8)
-			vals = append(vals, g8)
-			total += uint64(g8)
-		}
-	}
-	avg := uint8(total / 64)
-	var bits uint64
-	for i, v := range vals {
-		if v >= avg {
-			bits |= 1 << uint(i)
-		}
-	}
-	// return as 16-hex chars
-	const hexdigits = "0123456789abcdef"
-	out := make([]byte, 16)
-	for i := 0; i < 16; i++ {
-		nibble := (bits >> uint((15-i)*4)) & 0xF
-		out[i] = hexdigits[nibble]
+// decodeImage decodes an image file at full size.
+func decodeImage(src source, path string) (image.Image, error) {
+	f, err := src.open(path)
+	if err != nil {
+		return nil, err
 	}
-	return string(out)
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
 }
 
+// PerceptualHash computes the average hash (aHash) of img as 16 hex digits. Scans use the
+// algorithm selected by ScanConfig.PerceptualHash; see PerceptualHasherNames.
+func PerceptualHash(img image.Image) string { return hexHash(averageHash(img)) }
+
 // HammingDistanceHex between two equal-length hex strings (64-bit represented as 16 hex)
 func HammingDistanceHex(a, b string) int {
 	if len(a) != len(b) {
@@ -118,7 +107,8 @@ func HashMedia(path string, maxSide int) (string, error) {
 }
 
 // EstimateGroupSimilarity returns an approximate similarity percent [0,100] using the first file as reference.
-// Only meaningful for image/video groups.
+// Only meaningful for image/video groups. It decodes every file again; groups reported by a scan
+// carry the measured score in DuplicateGroup.Similarity instead.
 func EstimateGroupSimilarity(files []FileInfo) float64 {
 	if len(files) <= 1 {
 		return 100
@@ -48,21 +48,21 @@ func SaveThumbnail(originalPath string, maxSide int, img image.Image) error {
 	return png.Encode(f, img)
 }
 
-// GetMediaThumbnail returns a thumbnail for image/video based on extension.
+// GetMediaThumbnail returns a thumbnail for image/video based on the type sniffed from content.
 // It uses disk cache if available.
 func GetMediaThumbnail(path string, maxSide int) (image.Image, error) {
 	if img, err := LoadThumbnail(path, maxSide); err == nil && img != nil {
 		return img, nil
 	}
-	ext := strings.ToLower(filepath.Ext(path))
-	var (
-		img image.Image
-		err error
-	)
-	switch ext {
-	case ".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp":
+	mime, class, err := DetectFileType(path)
+	if err != nil {
+		return nil, err
+	}
+	var img image.Image
+	switch {
+	case decodableImages[mime]:
 		img, err = GenerateImageThumbnail(path, maxSide)
-	case ".mp4", ".mov", ".avi", ".mkv", ".wmv":
+	case class == ClassVideo:
 		img, err = GenerateVideoThumbnail(path, maxSide)
 	default:
 		err = os.ErrInvalid
@@ -75,6 +75,8 @@ func GetMediaThumbnail(path string, maxSide int) (image.Image, error) {
 
 func itoa(i int) string { return fmtInt(int64(i)) }
 
+func itoa64(i int64) string { return fmtInt(i) }
+
 func fmtInt(i int64) string {
 	// simple base-10 without strconv to avoid extra imports in this file
 	if i == 0 {
@@ -14,6 +14,20 @@ import (
 // GenerateVideoThumbnail extracts a frame using ffmpeg and returns a resized image.Image.
 // It requires ffmpeg to be available in PATH. This is a cross-platform placeholder implementation.
 func GenerateVideoThumbnail(path string, maxSide int) (image.Image, error) {
+	return videoThumbnail(context.Background(), path, maxSide)
+}
+
+// videoThumbnail is GenerateVideoThumbnail under ctx: cancelling it stops ffmpeg.
+func videoThumbnail(ctx context.Context, path string, maxSide int) (image.Image, error) {
+	img, err := videoFrame(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return shrink(img, maxSide), nil
+}
+
+// videoFrame extracts an early frame of the video at path at full size.
+func videoFrame(ctx context.Context, path string) (image.Image, error) {
 	// temp png path
 	base := filepath.Base(path)
 	tmp := filepath.Join(os.TempDir(), fmt.Sprintf("haste_thumb_%d_%s.png", time.Now().UnixNano(), base))
@@ -23,57 +37,82 @@ func GenerateVideoThumbnail(path string, maxSide int) (image.Image, error) {
 		bin = "ffmpeg"
 	}
 	// pick 1s as default seek position; add timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 	cmd := exec.CommandContext(ctx, bin, "-y", "-ss", "00:00:01.000", "-i", path, "-frames:v", "1", "-f", "image2", "-vcodec", "png", tmp)
 	if err := cmd.Run(); err != nil {
 		return nil, err
 	}
 	defer os.Remove(tmp)
-	// load and resize via existing image thumbnail util
-	return GenerateImageThumbnail(tmp, maxSide)
+	return decodeImage(source{}, tmp)
 }
 
-// VideoSimilarity groups videos by perceptual hash of an extracted frame.
+// VideoSimilarity groups videos by perceptual hash of an extracted frame, using
+// DefaultPerceptualHash and ClusterComponents.
 func VideoSimilarity(files []FileInfo, threshold int) []DuplicateGroup {
-	buckets := map[string][]FileInfo{}
-	hashes := map[string]string{}
-	for _, f := range files {
-		img, err := GenerateVideoThumbnail(f.Path, 128)
-		if err != nil {
-			continue
-		}
-		h := PerceptualHash(img)
-		hashes[f.Path] = h
+	ph, _ := LookupPerceptualHasher(DefaultPerceptualHash)
+	return summarizeGroups(videoSimilarity(newLimits(context.Background(), ScanConfig{}, nil), nil, files, ph, threshold, ClusterComponents))
+}
+
+// VideoEngine serves video mode: files whose content is video are grouped by a perceptual hash
+// of an early frame extracted with ffmpeg.
+type VideoEngine struct{}
+
+func (e VideoEngine) Scan(config ScanConfig) ([]DuplicateGroup, *ScanReport, error) {
+	return e.ScanContext(context.Background(), config)
+}
+
+func (VideoEngine) ScanContext(ctx context.Context, config ScanConfig) ([]DuplicateGroup, *ScanReport, error) {
+	ph, err := LookupPerceptualHasher(config.PerceptualHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	method, err := checkClusterMethod(config.Clustering)
+	if err != nil {
+		return nil, nil, err
 	}
-	for _, f := range files {
-		h, ok := hashes[f.Path]
-		if !ok {
-			continue
+	r, err := newScanRun(ctx, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.close()
+	r.walk(walkOptions{types: true})
+	// compare files whose content is video, whatever their extension
+	videoFiles := make([]FileInfo, 0, len(r.files))
+	for _, f := range r.files {
+		if f.Class == ClassVideo {
+			videoFiles = append(videoFiles, f)
 		}
-		placed := false
-		for key, list := range buckets {
-			if len(list) == 0 {
-				continue
-			}
-			ref := list[0]
-			dist := HammingDistanceHex(hashes[ref.Path], h)
-			if dist <= threshold {
-				buckets[key] = append(buckets[key], f)
-				placed = true
-				break
-			}
+	}
+	r.lim.prog.enter("grouping", totalBytes(videoFiles))
+	groups := videoSimilarity(r.lim, r.config.HashIndex, videoFiles, ph, hammingThreshold(config.SimilarityThreshold), method)
+	return r.done(r.addLinkGroups(groups, 0))
+}
+
+// videoSimilarity extracts and hashes frames on the worker pool, then clusters them.
+// Frame hashes made by ph and cached in idx are reused.
+func videoSimilarity(lim *limits, idx *HashIndex, files []FileInfo, ph PerceptualHasher, threshold int, method ClusterMethod) []DuplicateGroup {
+	hashes := make([]string, len(files))
+	lim.forEach(len(files), func(i int) {
+		defer lim.prog.advance(files[i].Path, files[i].SizeBytes)
+		if h, ok := idx.perceptualHash(files[i], ph.Name); ok {
+			hashes[i] = h
+			return
 		}
-		if !placed {
-			buckets[f.Path] = []FileInfo{f}
+		if !lim.src.isOS() {
+			lim.rep.addMedia(files[i].Path, errNotOnDisk, true) // ffmpeg reads from a path
+			return
 		}
-	}
-	out := make([]DuplicateGroup, 0, len(buckets))
-	for key, list := range buckets {
-		if len(list) < 2 {
-			continue
+		lim.acquireIO(files[i].Device)
+		img, err := videoFrame(lim.ctx, files[i].Path)
+		lim.releaseIO(files[i].Device)
+		if err != nil {
+			lim.rep.addMedia(files[i].Path, err, true)
+			return
 		}
-		out = append(out, DuplicateGroup{GroupID: key, Files: list})
-	}
-	return out
+		// as in image mode, the hash's own box filter reduces the full frame
+		hashes[i] = hexHash(ph.Hash(img))
+		idx.putPerceptualHash(files[i], ph.Name, hashes[i])
+	})
+	return clusterByHash(lim, files, hashes, ph.Name, threshold, method)
 }
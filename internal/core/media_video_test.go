@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeFFmpeg stands in for ffmpeg: every frame it extracts is frame.
+func fakeFFmpeg(t *testing.T, frame image.Image) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the stand-in is a shell script")
+	}
+	dir := t.TempDir()
+	framePath := filepath.Join(dir, "frame.png")
+	f, err := os.Create(framePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, frame); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	bin := filepath.Join(dir, "ffmpeg")
+	script := "#!/bin/sh\nfor a; do out=$a; done\ncp '" + framePath + "' \"$out\"\n"
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HASTE_FFMPEG_PATH", bin)
+}
+
+func TestVideoFramesAreHashedAtFullSize(t *testing.T) {
+	// fine stripes on a gradient: shrinking by sampling pixels aliases the stripes away
+	frame := image.NewGray(image.Rect(0, 0, 1024, 768))
+	for y := 0; y < 768; y++ {
+		for x := 0; x < 1024; x++ {
+			v := uint8(x / 8)
+			if x%8 < 3 {
+				v = 255 - uint8(y/4)
+			}
+			frame.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	fakeFFmpeg(t, frame)
+	dir := diskTree(t, map[string]string{"a.mp4": "not a video", "b.mp4": "not a video either"})
+	files := []FileInfo{{Path: filepath.Join(dir, "a.mp4")}, {Path: filepath.Join(dir, "b.mp4")}}
+	ph, err := LookupPerceptualHasher("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := videoSimilarity(newLimits(context.Background(), ScanConfig{}, nil), nil, files, ph, 0, ClusterComponents)
+	if len(groups) != 1 || len(groups[0].Files) != 2 {
+		t.Fatalf("groups %q", describe(groups))
+	}
+	if got, want := groups[0].Files[0].Hash, hexHash(ph.Hash(frame)); got != want {
+		t.Errorf("frame hash %s, want the full-size hash %s", got, want)
+	}
+}
@@ -1,40 +1,197 @@
 package core
 
+import (
+	"fmt"
+	"io/fs"
+	"time"
+)
+
 // ScanConfig represents user-configurable parameters for a scan session.
 type ScanConfig struct {
 	IncludePaths    []string
-	ExcludePatterns []string
-	Mode            string // basic | video | text | image
-	Concurrency     int
+	ExcludePatterns []string // gitignore-style rules relative to each include path, see PathRules
+	IncludePatterns []string // if set, only files matching these gitignore-style rules are scanned
+	Mode            string   // registered mode, see EngineModes: basic | image | text | video
+	Concurrency     int      // worker pool size; <= 0 uses the number of CPUs
+	IOConcurrency   int      // max concurrent file reads; 0 = Concurrency
+	HashConcurrency int      // max concurrent hash computations; 0 = Concurrency
+	PerDeviceIO     int      // if > 0, max concurrent reads per device (1 suits spinning disks)
 	// Filters
-	MinSizeBytes int64 // 0 = no min
-	MaxSizeBytes int64 // 0 = no max
+	MinSizeBytes       int64       // 0 = no min
+	MaxSizeBytes       int64       // 0 = no max
+	IncludeExtensions  []string    // if set, only files with one of these extensions ("jpg", ".tar.gz")
+	ExcludeExtensions  []string    // files with these extensions are skipped
+	ModifiedAfterUnix  int64       // if > 0, skip files modified before this time
+	ModifiedBeforeUnix int64       // if > 0, skip files modified at or after this time
+	TypeClasses        []FileClass // if set, only files whose content is of these classes
+	SkipHidden         bool        // skip hidden files and directories (dot names; hidden attribute on Windows)
+	SkipSystem         bool        // skip files and directories with the Windows system attribute
 	// Hashing / similarity
-	HashAlgorithm       string  // sha1 | sha256 | md5 (占位)
-	SimilarityThreshold float64 // 0.0-1.0 (媒体模式占位)
+	HashAlgorithm       string        // registered hasher name, see HasherNames (default sha256)
+	SimilarityThreshold float64       // text/image/video modes: 0.0-1.0, 0 picks the mode default
+	VerifyBytes         bool          // confirm exact groups with a byte-for-byte compare after hashing
+	PerceptualHash      string        // image/video modes: see PerceptualHasherNames (default phash)
+	Clustering          ClusterMethod // image/video modes: components (default) | complete | star
+	RotationInvariant   bool          // image mode: also match rotated and mirrored copies, upright per EXIF
+	TrimBorders         bool          // image mode: crop uniform borders and letterboxing before hashing
+	// Walk behaviour
+	SymlinkPolicy SymlinkPolicy // ignore (default) | follow | report
+	// DetectDirectories reports duplicate directories in basic mode and folds their file groups under them
+	DetectDirectories bool
+	// ScanArchives lists the members of zip and tar(.gz) archives as virtual files in basic mode,
+	// so a loose file already kept inside an archive shows up as its duplicate
+	ScanArchives bool
 	// Optional progress callback
-	OnProgress func(Progress)
-	// Future: hash algorithm, similarity threshold, size filters, presets
+	OnProgress func(Progress) `json:"-"`
+	// Optional callback receiving each group as soon as it is confirmed, while the scan runs.
+	// Calls are serialized but may come from any goroutine. The slice returned by the scan is
+	// the final arrangement: file groups folded into a directory group are streamed before it
+	OnGroup func(DuplicateGroup) `json:"-"`
+	// Optional pause/resume control for a running scan
+	Pauser *Pauser `json:"-"`
+	// Optional persistent hash cache; unchanged files reuse their stored hashes
+	HashIndex *HashIndex `json:"-"`
+	// Optional filesystem to scan instead of the OS one (fstest.MapFS, embed.FS, archive or
+	// overlay filesystems). IncludePaths are then names in it, "." being its root; symlinks are
+	// skipped, the hash index is not used and video mode is unavailable
+	FS fs.FS `json:"-"`
+}
+
+// SymlinkPolicy selects how symbolic links met during the walk are treated.
+type SymlinkPolicy string
+
+const (
+	SymlinksIgnore SymlinkPolicy = "ignore" // skip links entirely (default)
+	SymlinksFollow SymlinkPolicy = "follow" // scan link targets; directory cycles are walked once
+	SymlinksReport SymlinkPolicy = "report" // list links with their targets without hashing them
+)
+
+// SymlinkPolicies lists the supported symlink policies, the default first.
+func SymlinkPolicies() []SymlinkPolicy {
+	return []SymlinkPolicy{SymlinksIgnore, SymlinksFollow, SymlinksReport}
+}
+
+func checkSymlinkPolicy(p SymlinkPolicy) (SymlinkPolicy, error) {
+	if p == "" {
+		return SymlinksIgnore, nil
+	}
+	for _, known := range SymlinkPolicies() {
+		if p == known {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown symlink policy %q (available: ignore, follow, report)", p)
 }
 
 // FileInfo represents a single file discovered by the scanner.
 type FileInfo struct {
-	Path         string
-	SizeBytes    int64
-	ModifiedUnix int64
-	Hash         string
-	Type         string // mime or coarse type
+	Path          string
+	SizeBytes     int64
+	ModifiedUnix  int64
+	Hash          string
+	HashAlgorithm string    // hasher that produced Hash; hashes of different algorithms never compare equal
+	Device        uint64    // filesystem device (volume on Windows), used for per-device I/O limits
+	Inode         uint64    // inode (file index on Windows); 0 if unknown
+	Links         []string  // other scanned paths that are hardlinks to the same inode
+	LinkTarget    string    // symlink destination, only for entries reported under SymlinksReport
+	Type          string    // MIME type sniffed from the content, e.g. image/jpeg; empty if the file was never read
+	Class         FileClass // coarse class of Type; empty if the file was never read
+	IsDir         bool      // entry stands for a whole directory in a directory group
+	Archive       string    // archive holding this virtual member; Path is Archive + "!/" + member name
+
+	modNano int64  // full-precision mtime, part of the hash index key
+	sample  string // sample hash of an archive member, computed while streaming it
 }
 
+// MatchKind describes why the files of a group were grouped together.
+type MatchKind string
+
+const (
+	MatchExact      MatchKind = "exact"      // identical content, confirmed by full hash
+	MatchPrefix     MatchKind = "prefix"     // same size and head/tail sample, different content; not duplicates
+	MatchPerceptual MatchKind = "perceptual" // similar images or video frames by perceptual hash
+	MatchText       MatchKind = "text"       // near-duplicate text after normalization
+	MatchHardlink   MatchKind = "linked"     // one inode reachable by several paths; already a single copy
+	MatchSymlink    MatchKind = "symlink"    // a symbolic link and the file it points to; not duplicates
+	// MatchDirectory groups directories with identical names and contents; Files are the directories.
+	MatchDirectory MatchKind = "dir"
+	// MatchDirSubset pairs a directory (Files[1]) whose files all also exist in another one (Files[0]).
+	MatchDirSubset MatchKind = "dir-subset"
+)
+
 // DuplicateGroup represents a logical group of duplicate files.
 type DuplicateGroup struct {
 	GroupID string
-	Files   []FileInfo
+	Kind    MatchKind
+	// Files are the members of the group. Hash holds the value they were matched by: the content
+	// hash for exact and prefix groups, the perceptual hash for perceptual groups, with the
+	// algorithm in HashAlgorithm. Text groups compare signatures and leave it empty.
+	Files []FileInfo
+	// Similarity is the similarity (0-1) measured by the scan: 1 for exact, linked and directory
+	// groups, the estimated Jaccard similarity for text groups, and 1 - the mean of Distances / 64
+	// for perceptual groups. Prefix and symlink groups report 0.
+	Similarity float64
+	// TotalBytes is the size of all files of the group; ReclaimableBytes is the space freed by
+	// keeping one of them and removing the rest, counting each inode once. Both are filled in
+	// when the scan reports the group.
+	TotalBytes       int64
+	ReclaimableBytes int64
+	// Distances lists the perceptual distances measured between members of an image or video
+	// group: every pair within the threshold. Pairs further apart, which a group formed by
+	// ClusterComponents can hold, are not listed.
+	Distances []PairDistance `json:",omitempty"`
+	// Incomplete is set when the scan was cancelled: other copies may not have been examined.
+	Incomplete bool
+	// Collapsed holds the file groups that lie entirely inside the directories of a directory group.
+	Collapsed []DuplicateGroup `json:",omitempty"`
+}
+
+// summarize fills the figures derived from the files of g and its collapsed groups, so that
+// consumers read them instead of recomputing them.
+func (g *DuplicateGroup) summarize() {
+	g.TotalBytes = 0
+	for _, f := range g.Files {
+		g.TotalBytes += f.SizeBytes
+	}
+	g.ReclaimableBytes = g.reclaimable()
+	switch g.Kind {
+	case MatchExact, MatchHardlink, MatchDirectory:
+		g.Similarity = 1
+	}
+	for i := range g.Collapsed {
+		g.Collapsed[i].summarize()
+	}
+}
+
+// summarizeGroups calls summarize on every group and returns groups.
+func summarizeGroups(groups []DuplicateGroup) []DuplicateGroup {
+	for i := range groups {
+		groups[i].summarize()
+	}
+	return groups
+}
+
+// PairDistance is the distance measured between two files of a group.
+type PairDistance struct {
+	A, B int // indexes into DuplicateGroup.Files
+	Bits int // differing perceptual hash bits, out of 64
+	// Transform turns Files[A] into Files[B]; set only by ScanConfig.RotationInvariant.
+	Transform Transform `json:",omitempty"`
 }
 
-// Progress provides lightweight telemetry from scanner to UI/CLI.
+// Progress provides lightweight telemetry from scanner to UI/CLI. It is reported on every stage
+// change and periodically while a stage runs.
 type Progress struct {
-	Stage        string // "walking" | "hashing" | "grouping" | "done"
+	Stage        string // "walking" | "archive" | "hashing" | "grouping" | "done" | "cancelled"
 	FilesScanned int
 	GroupsFound  int
+	BytesFound   int64  // total size of the files discovered so far
+	CurrentPath  string // file being walked or read
+	// Byte work of the current stage: content hashed in basic mode, files decoded or compared in
+	// similarity modes. BytesToHash can grow while hashing, as sample collisions need full hashes.
+	BytesHashed    int64
+	BytesToHash    int64
+	StagePercent   float64       // 0-100; -1 while the stage's workload is unknown, as during the walk
+	BytesPerSecond float64       // average throughput of the current stage
+	ETA            time.Duration // estimated time left in the current stage; 0 if unknown
 }
@@ -0,0 +1,280 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"strings"
+)
+
+// Transform is a rotation or mirroring of an image, one of the eight symmetries of a rectangle.
+// Rotations are clockwise.
+type Transform string
+
+const (
+	TransformNone           Transform = ""
+	TransformRotate90       Transform = "rotate90"
+	TransformRotate180      Transform = "rotate180"
+	TransformRotate270      Transform = "rotate270"
+	TransformFlipHorizontal Transform = "flip-horizontal" // mirrored left to right
+	TransformFlipVertical   Transform = "flip-vertical"   // mirrored top to bottom
+	TransformTranspose      Transform = "transpose"       // mirrored along the main diagonal
+	TransformTransverse     Transform = "transverse"      // mirrored along the other diagonal
+)
+
+// transforms lists the symmetries in the order their hashes are stored, the identity first.
+var transforms = [8]Transform{
+	TransformNone, TransformRotate90, TransformRotate180, TransformRotate270,
+	TransformFlipHorizontal, TransformFlipVertical, TransformTranspose, TransformTransverse,
+}
+
+// inverse is the transform that undoes t.
+func (t Transform) inverse() Transform {
+	switch t {
+	case TransformRotate90:
+		return TransformRotate270
+	case TransformRotate270:
+		return TransformRotate90
+	}
+	return t // every other symmetry is its own inverse
+}
+
+// transformGrid returns t applied to the n x n grid v.
+func transformGrid(v []float64, n int, t Transform) []float64 {
+	if t == TransformNone {
+		return v
+	}
+	out := make([]float64, len(v))
+	m := n - 1
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			var sx, sy int
+			switch t {
+			case TransformRotate90:
+				sx, sy = y, m-x
+			case TransformRotate180:
+				sx, sy = m-x, m-y
+			case TransformRotate270:
+				sx, sy = m-y, x
+			case TransformFlipHorizontal:
+				sx, sy = m-x, y
+			case TransformFlipVertical:
+				sx, sy = x, m-y
+			case TransformTranspose:
+				sx, sy = y, x
+			case TransformTransverse:
+				sx, sy = m-y, m-x
+			}
+			out[y*n+x] = v[sy*n+sx]
+		}
+	}
+	return out
+}
+
+// exifTransforms maps the EXIF Orientation tag to the transform that turns the stored pixels
+// upright.
+var exifTransforms = [...]Transform{
+	2: TransformFlipHorizontal, 3: TransformRotate180, 4: TransformFlipVertical,
+	5: TransformTranspose, 6: TransformRotate90, 7: TransformTransverse, 8: TransformRotate270,
+}
+
+// exifHead is how much of a file is searched for EXIF data; a JPEG APP1 segment holds at most 64 KiB.
+const exifHead = 128 << 10
+
+// exifOrientation reads the Orientation tag from the start of a JPEG or TIFF file and returns
+// the transform that makes the image upright; TransformNone when there is none.
+func exifOrientation(head []byte) Transform {
+	tiff := head
+	if bytes.HasPrefix(head, []byte{0xFF, 0xD8}) {
+		tiff = nil
+		// walk the segments before the image data for APP1 "Exif"
+		for p := 2; p+4 <= len(head) && head[p] == 0xFF; {
+			marker := head[p+1]
+			if marker == 0xDA { // start of scan
+				break
+			}
+			n := int(binary.BigEndian.Uint16(head[p+2:]))
+			if marker == 0xE1 && n >= 8 && p+2+n <= len(head) && bytes.HasPrefix(head[p+4:], []byte("Exif\x00\x00")) {
+				tiff = head[p+10 : p+2+n]
+				break
+			}
+			p += 2 + n
+		}
+	}
+	if len(tiff) < 8 {
+		return TransformNone
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return TransformNone
+	}
+	// the tag lives in the first directory: a count, then 12-byte entries
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return TransformNone
+	}
+	for i := 0; i < int(order.Uint16(tiff[ifd:])); i++ {
+		e := ifd + 2 + 12*i
+		if e+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[e:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[e+8:])); v < len(exifTransforms) {
+				return exifTransforms[v]
+			}
+			break
+		}
+	}
+	return TransformNone
+}
+
+// decodeOrientedImage decodes an image file along with the transform its EXIF data asks for.
+func decodeOrientedImage(src source, path string) (image.Image, Transform, error) {
+	f, err := src.open(path)
+	if err != nil {
+		return nil, TransformNone, err
+	}
+	defer f.Close()
+	br := bufio.NewReaderSize(f, exifHead)
+	head, _ := br.Peek(exifHead) // a shorter file yields what there is
+	upright := exifOrientation(head)
+	img, _, err := image.Decode(br)
+	return img, upright, err
+}
+
+// trimBorders crops uniform bands off the edges of img: frames, mats and the bars of
+// letterboxed or pillarboxed pictures. A band is a run of rows or columns that each hold a
+// single tone, the same tone as the outermost one, up to 40% of the image per side.
+// An image that is uniform throughout is returned as is.
+func trimBorders(img image.Image) image.Image {
+	b := img.Bounds()
+	if b.Dx() < 8 || b.Dy() < 8 {
+		return img
+	}
+	gray := grayFunc(img)
+	const spread, drift = 24.0, 12.0 // tolerances for JPEG noise within a line and along a band
+	// flat reports the tone of the n pixels at (x0,y0) + i*(dx,dy), sampling long lines
+	flat := func(x0, y0, dx, dy, n int) (float64, bool) {
+		step := 1 + n/512
+		lo, hi, sum, count := 255.0, 0.0, 0.0, 0
+		for i := 0; i < n; i += step {
+			v := gray(x0+i*dx, y0+i*dy)
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+			sum += v
+			count++
+		}
+		return sum / float64(count), hi-lo <= spread
+	}
+	// band counts the flat lines from the edge inwards, line i starting at (x0,y0) + i*(ix,iy)
+	band := func(x0, y0, ix, iy, dx, dy, n, limit int) int {
+		ref, ok := flat(x0, y0, dx, dy, n)
+		if !ok {
+			return 0
+		}
+		k := 1
+		for ; k < limit; k++ {
+			tone, ok := flat(x0+k*ix, y0+k*iy, dx, dy, n)
+			if !ok || tone-ref > drift || ref-tone > drift {
+				break
+			}
+		}
+		return k
+	}
+	// bands are measured in full, so bands that meet tell a uniform image, then capped
+	capped := func(n, size int) int {
+		if n > size*2/5 {
+			return size * 2 / 5
+		}
+		return n
+	}
+	w, h := b.Dx(), b.Dy()
+	top := band(b.Min.X, b.Min.Y, 0, 1, 1, 0, w, h)
+	bottom := band(b.Min.X, b.Max.Y-1, 0, -1, 1, 0, w, h)
+	if top+bottom >= h {
+		return img
+	}
+	top, bottom = capped(top, h), capped(bottom, h)
+	inner := h - top - bottom
+	left := band(b.Min.X, b.Min.Y+top, 1, 0, 0, 1, inner, w)
+	right := band(b.Max.X-1, b.Min.Y+top, -1, 0, 0, 1, inner, w)
+	if left+right >= w {
+		return img
+	}
+	left, right = capped(left, w), capped(right, w)
+	if top+bottom+left+right == 0 {
+		return img
+	}
+	sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	if !ok {
+		return img
+	}
+	return sub.SubImage(image.Rect(b.Min.X+left, b.Min.Y+top, b.Max.X-right, b.Max.Y-bottom))
+}
+
+// imageHashing is how image mode hashes a decoded image. With turns or trim set the image is
+// first turned upright per its EXIF data, trimmed when asked, and reduced to a 64x64 grid that
+// every orientation is hashed from; turning the grid is the same as turning the image.
+type imageHashing struct {
+	ph    PerceptualHasher
+	turns bool // hash all eight orientations, so rotated and mirrored copies match
+	trim  bool // crop uniform borders first
+}
+
+// normalized reports whether images go through the upright grid.
+func (h imageHashing) normalized() bool { return h.turns || h.trim }
+
+// key names the hashes in the hash index, so each variant caches its own.
+func (h imageHashing) key() string {
+	key := h.ph.Name
+	if h.turns {
+		key += "+turns"
+	}
+	if h.trim {
+		key += "+trim"
+	}
+	return key
+}
+
+// hash returns the hash of img stored by the image mode: 16 hex digits, or with turns the
+// hashes of every orientation in the order of transforms, 16 digits each.
+func (h imageHashing) hash(img image.Image, upright Transform) string {
+	if !h.normalized() {
+		return hexHash(h.ph.Hash(img))
+	}
+	const n = 64
+	if h.trim {
+		img = trimBorders(img)
+	}
+	grid := transformGrid(boxGray(img, n, n), n, upright)
+	if !h.turns {
+		return hexHash(h.ph.Hash(grayImage(grid, n)))
+	}
+	var sb strings.Builder
+	for _, t := range transforms {
+		sb.WriteString(hexHash(h.ph.Hash(grayImage(transformGrid(grid, n, t), n))))
+	}
+	return sb.String()
+}
+
+// grayImage wraps an n x n luma grid as an image for the hashers.
+func grayImage(v []float64, n int) image.Image {
+	img := image.NewGray16(image.Rect(0, 0, n, n))
+	for i, x := range v {
+		c := uint16(x*257 + 0.5)
+		img.Pix[2*i], img.Pix[2*i+1] = uint8(c>>8), uint8(c)
+	}
+	return img
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// exifTIFF builds a TIFF header with a first directory holding an image width and, unless
+// orientation is 0, an Orientation tag.
+func exifTIFF(order binary.ByteOrder, orientation uint16) []byte {
+	var b bytes.Buffer
+	if order == binary.LittleEndian {
+		b.WriteString("II*\x00")
+	} else {
+		b.WriteString("MM\x00*")
+	}
+	binary.Write(&b, order, uint32(8))
+	entries := [][3]uint16{{0x0100, 3, 640}} // ImageWidth
+	if orientation != 0 {
+		entries = append(entries, [3]uint16{0x0112, 3, orientation})
+	}
+	binary.Write(&b, order, uint16(len(entries)))
+	for _, e := range entries {
+		binary.Write(&b, order, e[0])      // tag
+		binary.Write(&b, order, e[1])      // type SHORT
+		binary.Write(&b, order, uint32(1)) // count
+		binary.Write(&b, order, e[2])      // value, left-justified
+		binary.Write(&b, order, uint16(0)) // padding
+	}
+	binary.Write(&b, order, uint32(0)) // no next directory
+	return b.Bytes()
+}
+
+// jpegSegment is a marker segment with its length.
+func jpegSegment(marker byte, payload []byte) []byte {
+	seg := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+func exifJPEG(segments ...[]byte) []byte {
+	out := []byte{0xFF, 0xD8}
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+	return append(out, 0xFF, 0xDA, 0, 2) // start of scan
+}
+
+func exifApp1(tiff []byte) []byte {
+	return jpegSegment(0xE1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+func TestExifOrientation(t *testing.T) {
+	jfif := jpegSegment(0xE0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"))
+	truncated := exifJPEG(exifApp1(exifTIFF(binary.LittleEndian, 6)))
+	truncated = truncated[:len(truncated)-12]
+	tests := []struct {
+		name string
+		head []byte
+		want Transform
+	}{
+		{"empty", nil, TransformNone},
+		{"jpeg without exif", exifJPEG(jfif), TransformNone},
+		{"little endian", exifJPEG(exifApp1(exifTIFF(binary.LittleEndian, 6))), TransformRotate90},
+		{"big endian", exifJPEG(exifApp1(exifTIFF(binary.BigEndian, 8))), TransformRotate270},
+		{"after jfif", exifJPEG(jfif, exifApp1(exifTIFF(binary.BigEndian, 3))), TransformRotate180},
+		{"mirrored", exifJPEG(exifApp1(exifTIFF(binary.LittleEndian, 5))), TransformTranspose},
+		{"upright", exifJPEG(exifApp1(exifTIFF(binary.LittleEndian, 1))), TransformNone},
+		{"no orientation tag", exifJPEG(exifApp1(exifTIFF(binary.LittleEndian, 0))), TransformNone},
+		{"out of range", exifJPEG(exifApp1(exifTIFF(binary.LittleEndian, 9))), TransformNone},
+		{"truncated", truncated, TransformNone},
+		{"tiff file", exifTIFF(binary.BigEndian, 2), TransformFlipHorizontal},
+		{"not an image", []byte("hello, world"), TransformNone},
+	}
+	for _, tt := range tests {
+		if got := exifOrientation(tt.head); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTransformGrid(t *testing.T) {
+	// 0 1
+	// 2 3
+	grid := []float64{0, 1, 2, 3}
+	tests := []struct {
+		t    Transform
+		want []float64
+	}{
+		{TransformNone, []float64{0, 1, 2, 3}},
+		{TransformRotate90, []float64{2, 0, 3, 1}},
+		{TransformRotate180, []float64{3, 2, 1, 0}},
+		{TransformRotate270, []float64{1, 3, 0, 2}},
+		{TransformFlipHorizontal, []float64{1, 0, 3, 2}},
+		{TransformFlipVertical, []float64{2, 3, 0, 1}},
+		{TransformTranspose, []float64{0, 2, 1, 3}},
+		{TransformTransverse, []float64{3, 1, 2, 0}},
+	}
+	equal := func(a, b []float64) bool {
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return len(a) == len(b)
+	}
+	for _, tt := range tests {
+		got := transformGrid(grid, 2, tt.t)
+		if !equal(got, tt.want) {
+			t.Errorf("%q: %v, want %v", tt.t, got, tt.want)
+		}
+		if back := transformGrid(got, 2, tt.t.inverse()); !equal(back, grid) {
+			t.Errorf("%q then its inverse: %v", tt.t, back)
+		}
+	}
+}
+
+func TestTrimBorders(t *testing.T) {
+	// picture draws a w x h image: a detailed middle inside the border rectangle, a tone outside
+	picture := func(w, h int, inner image.Rectangle, tone uint8) *image.Gray {
+		img := image.NewGray(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				v := tone + uint8((x+y)%3) // a little noise, as JPEG leaves
+				if (image.Point{x, y}).In(inner) {
+					v = uint8((x*37 + y*91) % 256)
+				}
+				img.SetGray(x, y, color.Gray{Y: v})
+			}
+		}
+		return img
+	}
+	full := image.Rect(0, 0, 100, 80)
+	tests := []struct {
+		name string
+		img  image.Image
+		want image.Rectangle
+	}{
+		{"no border", picture(100, 80, full, 0), full},
+		{"letterbox", picture(100, 80, image.Rect(0, 10, 100, 70), 0), image.Rect(0, 10, 100, 70)},
+		{"pillarbox", picture(100, 80, image.Rect(15, 0, 85, 80), 0), image.Rect(15, 0, 85, 80)},
+		{"white frame", picture(100, 80, image.Rect(5, 4, 95, 76), 240), image.Rect(5, 4, 95, 76)},
+		{"one side", picture(100, 80, image.Rect(0, 0, 100, 60), 30), image.Rect(0, 0, 100, 60)},
+		{"at most 40% a side", picture(100, 80, image.Rect(0, 50, 100, 80), 0), image.Rect(0, 32, 100, 80)},
+		{"uniform", picture(100, 80, image.Rectangle{}, 128), full},
+		{"too small", picture(6, 6, image.Rect(1, 1, 5, 5), 0), image.Rect(0, 0, 6, 6)},
+	}
+	for _, tt := range tests {
+		if got := trimBorders(tt.img).Bounds(); got != tt.want {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
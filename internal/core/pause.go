@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// Pauser lets a caller suspend a running scan and resume it later without losing partial state.
+// Workers block in Wait while paused. A nil *Pauser is never paused.
+type Pauser struct {
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{}
+}
+
+func NewPauser() *Pauser { return &Pauser{} }
+
+// Pause suspends workers at their next checkpoint.
+func (p *Pauser) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		p.paused = true
+		p.resume = make(chan struct{})
+	}
+}
+
+// Resume releases all workers blocked in Wait.
+func (p *Pauser) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused {
+		p.paused = false
+		close(p.resume)
+	}
+}
+
+// Paused reports whether the scan is currently paused.
+func (p *Pauser) Paused() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// Wait blocks while paused and returns ctx.Err() if the context is cancelled.
+func (p *Pauser) Wait(ctx context.Context) error {
+	if p != nil {
+		p.mu.Lock()
+		paused, resume := p.paused, p.resume
+		p.mu.Unlock()
+		if paused {
+			select {
+			case <-resume:
+			case <-ctx.Done():
+			}
+		}
+	}
+	return ctx.Err()
+}
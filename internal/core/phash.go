@@ -0,0 +1,248 @@
+package core
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultPerceptualHash is used when ScanConfig.PerceptualHash is empty.
+const DefaultPerceptualHash = "phash"
+
+// PerceptualHasher describes a perceptual hash algorithm for images and video frames. Every
+// algorithm yields 64 bits, written as 16 hex digits, so hashes compare with HammingDistanceHex;
+// hashes of different algorithms are never compared.
+type PerceptualHasher struct {
+	Name        string
+	Description string
+	Hash        func(img image.Image) uint64
+}
+
+var (
+	perceptualHashersMu sync.RWMutex
+	perceptualHashers   = map[string]PerceptualHasher{}
+)
+
+// RegisterPerceptualHasher adds or replaces a perceptual hash algorithm in the registry. It is
+// safe to call while scans run.
+func RegisterPerceptualHasher(h PerceptualHasher) {
+	perceptualHashersMu.Lock()
+	defer perceptualHashersMu.Unlock()
+	perceptualHashers[strings.ToLower(h.Name)] = h
+}
+
+// LookupPerceptualHasher resolves an algorithm by name; an empty name selects DefaultPerceptualHash.
+func LookupPerceptualHasher(name string) (PerceptualHasher, error) {
+	if name == "" {
+		name = DefaultPerceptualHash
+	}
+	perceptualHashersMu.RLock()
+	h, ok := perceptualHashers[strings.ToLower(name)]
+	perceptualHashersMu.RUnlock()
+	if !ok {
+		return PerceptualHasher{}, fmt.Errorf("unknown perceptual hash %q (available: %s)", name, strings.Join(PerceptualHasherNames(), ", "))
+	}
+	return h, nil
+}
+
+// PerceptualHasherNames lists registered perceptual hash names in sorted order.
+func PerceptualHasherNames() []string {
+	perceptualHashersMu.RLock()
+	names := make([]string, 0, len(perceptualHashers))
+	for name := range perceptualHashers {
+		names = append(names, name)
+	}
+	perceptualHashersMu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterPerceptualHasher(PerceptualHasher{Name: "ahash", Description: "average hash: 8x8 means against their mean", Hash: averageHash})
+	RegisterPerceptualHasher(PerceptualHasher{Name: "dhash", Description: "difference hash: brightness gradient between neighbouring cells", Hash: differenceHash})
+	RegisterPerceptualHasher(PerceptualHasher{Name: "phash", Description: "DCT hash: lowest 8x8 frequencies of a 32x32 image against their median", Hash: dctHash})
+	RegisterPerceptualHasher(PerceptualHasher{Name: "whash", Description: "wavelet hash: Haar approximation band at 8x8 against its median", Hash: waveletHash})
+}
+
+// hexHash writes bits as the 16 hex digits stored for perceptual hashes.
+func hexHash(bits uint64) string { return fmt.Sprintf("%016x", bits) }
+
+// grayFunc returns the luma reader of img, with direct access for the decoders' common types.
+func grayFunc(img image.Image) func(x, y int) float64 {
+	switch m := img.(type) {
+	case *image.YCbCr: // JPEG: the Y plane is the luma already
+		return func(x, y int) float64 { return float64(m.Y[m.YOffset(x, y)]) }
+	case *image.Gray:
+		return func(x, y int) float64 { return float64(m.Pix[m.PixOffset(x, y)]) }
+	case *image.Gray16: // the grids of the orientation-tolerant hashes
+		return func(x, y int) float64 {
+			p := m.Pix[m.PixOffset(x, y):]
+			return float64(uint16(p[0])<<8|uint16(p[1])) / 257
+		}
+	case *image.RGBA:
+		return func(x, y int) float64 {
+			p := m.Pix[m.PixOffset(x, y):]
+			return 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+		}
+	}
+	return func(x, y int) float64 {
+		r, g, b, _ := img.At(x, y).RGBA()
+		return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+	}
+}
+
+// boxGray downscales img to a w x h luma grid where every cell is the mean of the pixels it
+// covers, so recompression noise and single pixels average out instead of being sampled.
+// Cells of an image smaller than the grid take the nearest pixel.
+func boxGray(img image.Image, w, h int) []float64 {
+	out := make([]float64, w*h)
+	b := img.Bounds()
+	W, H := b.Dx(), b.Dy()
+	if W == 0 || H == 0 {
+		return out
+	}
+	gray := grayFunc(img)
+	count := make([]int, w*h)
+	for y := 0; y < H; y++ {
+		row := (y * h / H) * w
+		for x := 0; x < W; x++ {
+			c := row + x*w/W
+			out[c] += gray(b.Min.X+x, b.Min.Y+y)
+			count[c]++
+		}
+	}
+	for i := range out {
+		if count[i] > 0 {
+			out[i] /= float64(count[i])
+		} else {
+			out[i] = gray(b.Min.X+(i%w)*W/w, b.Min.Y+(i/w)*H/h)
+		}
+	}
+	return out
+}
+
+// thresholdBits sets bit i when v[i] is above t.
+func thresholdBits(v []float64, t float64) uint64 {
+	var bits uint64
+	for i, x := range v {
+		if x > t {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits
+}
+
+func median(v []float64) float64 {
+	s := append([]float64(nil), v...)
+	sort.Float64s(s)
+	return (s[len(s)/2-1] + s[len(s)/2]) / 2
+}
+
+// averageHash compares each cell of an 8x8 downscale with the mean.
+func averageHash(img image.Image) uint64 {
+	v := boxGray(img, 8, 8)
+	mean := 0.0
+	for _, x := range v {
+		mean += x
+	}
+	return thresholdBits(v, mean/64)
+}
+
+// differenceHash compares horizontally adjacent cells of a 9x8 downscale, which tracks
+// gradients and so ignores uniform brightness and contrast changes.
+func differenceHash(img image.Image) uint64 {
+	v := boxGray(img, 9, 8)
+	var bits uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if v[y*9+x] < v[y*9+x+1] {
+				bits |= 1 << uint(y*8+x)
+			}
+		}
+	}
+	return bits
+}
+
+// dctCos[k][n] is the DCT-II basis cos(pi/32 * (n+0.5) * k) for the 32-point transform.
+var dctCos = func() (t [8][32]float64) {
+	for k := range t {
+		for n := range t[k] {
+			t[k][n] = math.Cos(math.Pi / 32 * (float64(n) + 0.5) * float64(k))
+		}
+	}
+	return
+}()
+
+// dctHash is the classic pHash: the 2D DCT of a 32x32 downscale, of which only the 8x8 lowest
+// frequencies are kept, each compared with their median. Compression artefacts live in the high
+// frequencies it drops, and a brightness change only moves the DC term.
+func dctHash(img image.Image) uint64 {
+	const n, k = 32, 8
+	v := boxGray(img, n, n)
+	// rows first, keeping only the low frequencies, then columns
+	var rows [n][k]float64
+	for y := 0; y < n; y++ {
+		for u := 0; u < k; u++ {
+			s := 0.0
+			for x := 0; x < n; x++ {
+				s += v[y*n+x] * dctCos[u][x]
+			}
+			rows[y][u] = s
+		}
+	}
+	coef := make([]float64, k*k)
+	for w := 0; w < k; w++ {
+		for u := 0; u < k; u++ {
+			s := 0.0
+			for y := 0; y < n; y++ {
+				s += rows[y][u] * dctCos[w][y]
+			}
+			coef[w*k+u] = s
+		}
+	}
+	return thresholdBits(coef, median(coef))
+}
+
+// waveletHash decomposes a 64x64 downscale with three levels of the 2D Haar transform and
+// compares the 8x8 approximation band with its median. Against its median rather than a mean,
+// as in averageHash, the bits survive brightness and contrast changes.
+func waveletHash(img image.Image) uint64 {
+	const n = 64
+	v := boxGray(img, n, n)
+	for size := n; size > 8; size /= 2 {
+		haarStep(v, n, size)
+	}
+	ll := make([]float64, 0, 64)
+	for y := 0; y < 8; y++ {
+		ll = append(ll, v[y*n:y*n+8]...)
+	}
+	return thresholdBits(ll, median(ll))
+}
+
+// haarStep applies one level of the 2D Haar transform to the top-left size x size block of the
+// stride-wide grid v, leaving the approximation band in its top-left quarter.
+func haarStep(v []float64, stride, size int) {
+	half := size / 2
+	tmp := make([]float64, size)
+	for y := 0; y < size; y++ {
+		row := v[y*stride : y*stride+size]
+		for x := 0; x < half; x++ {
+			tmp[x] = (row[2*x] + row[2*x+1]) / math.Sqrt2
+			tmp[half+x] = (row[2*x] - row[2*x+1]) / math.Sqrt2
+		}
+		copy(row, tmp)
+	}
+	for x := 0; x < size; x++ {
+		for y := 0; y < half; y++ {
+			a, b := v[2*y*stride+x], v[(2*y+1)*stride+x]
+			tmp[y] = (a + b) / math.Sqrt2
+			tmp[half+y] = (a - b) / math.Sqrt2
+		}
+		for y := 0; y < size; y++ {
+			v[y*stride+x] = tmp[y]
+		}
+	}
+}
@@ -4,76 +4,193 @@ package core
 type ActionType string
 
 const (
-    ActionDelete    ActionType = "delete"   // 删除文件（占位）
-    ActionRecycle   ActionType = "recycle"  // 移至回收站（占位）
-    ActionMove      ActionType = "move"     // 移动到目录
-    ActionCopy      ActionType = "copy"     // 复制到目录
-    ActionRename    ActionType = "rename"   // 重命名/加后缀
-    ActionMark      ActionType = "mark"     // 标记（元数据/DB，占位）
+	ActionDelete  ActionType = "delete"  // 删除文件（占位）
+	ActionRecycle ActionType = "recycle" // 移至回收站（占位）
+	ActionMove    ActionType = "move"    // 移动到目录
+	ActionCopy    ActionType = "copy"    // 复制到目录
+	ActionRename  ActionType = "rename"  // 重命名/加后缀
+	ActionMark    ActionType = "mark"    // 标记（元数据/DB，占位）
 )
 
 // PolicyRule defines one rule used to decide which files to keep or operate.
+// BuildPlan does not consult it: the copy a group keeps is chosen as BuildPlan describes.
 type PolicyRule struct {
-    KeepNewest      bool
-    KeepOldest      bool
-    KeepShortestDir bool
-    // more: by path contains, by extension, etc.
+	KeepNewest      bool
+	KeepOldest      bool
+	KeepShortestDir bool
+	// more: by path contains, by extension, etc.
 }
 
 // Policy defines a high level strategy template.
 type Policy struct {
-    Name        string
-    Description string
-    Rule        PolicyRule
-    Action      Action
+	Name        string
+	Description string
+	Rule        PolicyRule
+	Action      Action
 }
 
 // Action holds parameters for an operation to apply on selected files.
 type Action struct {
-    Type           ActionType
-    DestinationDir string // for move/copy
-    RenameSuffix   string // for rename
-    DryRun         bool   // preview only
+	Type           ActionType
+	DestinationDir string // for move/copy
+	RenameSuffix   string // for rename
+	DryRun         bool   // preview only
 }
 
 // PlanItem represents a single file operation in preview/execution.
 type PlanItem struct {
-    GroupID string
-    Source  FileInfo
-    Target  string // path or new name
-    Action  ActionType
+	GroupID string
+	Source  FileInfo
+	Target  string // path or new name
+	Action  ActionType
 }
 
 // BuildPlan creates a naive plan: keep first in each group, operate others by policy.Action.
+// Prefix-match candidates, hardlink sets and symlinks are not duplicates and never produce plan items.
+// Deleting or moving a file with hardlinks covers every path, otherwise no space would be freed.
+// Directory groups act on whole directories: the first is kept, so for a subset pair the
+// superset stays and the subset directory is deleted or moved. When the action removes files,
+// file groups follow the directory groups so no copy is lost between them: a file group keeps
+// its copy inside a kept directory, or else one outside every removed directory, and leaves
+// the files inside either kind of directory to the directory groups.
 func BuildPlan(groups []DuplicateGroup, p Policy) []PlanItem {
-    var plan []PlanItem
-    for _, g := range groups {
-        if len(g.Files) <= 1 {
-            continue
-        }
-        keeperIdx := 0 // TODO: apply Rule
-        for i, f := range g.Files {
-            if i == keeperIdx {
-                continue
-            }
-            var target string
-            switch p.Action.Type {
-            case ActionMove, ActionCopy:
-                target = p.Action.DestinationDir
-            case ActionRename:
-                target = f.Path + p.Action.RenameSuffix
-            default:
-                target = ""
-            }
-            plan = append(plan, PlanItem{
-                GroupID: g.GroupID,
-                Source:  f,
-                Target:  target,
-                Action:  p.Action.Type,
-            })
-        }
-    }
-    return plan
+	dirs := newPlanDirs(groups, p.Action.Type)
+	// keepers first: a file group whose every copy lies in removed directories spares one
+	keepers := make([]int, len(groups))
+	for i, g := range groups {
+		if !isDirGroup(g) {
+			keepers[i] = dirs.keeper(g)
+		}
+	}
+	var plan []PlanItem
+	for gi, g := range groups {
+		if len(g.Files) <= 1 || g.Kind == MatchPrefix || g.Kind == MatchHardlink || g.Kind == MatchSymlink {
+			continue
+		}
+		keeperIdx := keepers[gi]
+		for i, f := range g.Files {
+			if i == keeperIdx {
+				continue
+			}
+			if isDirGroup(g) && dirs.spared[f.Path] || !isDirGroup(g) && dirs.decides(f) {
+				continue
+			}
+			paths := []string{f.Path}
+			switch p.Action.Type {
+			case ActionDelete, ActionRecycle, ActionMove:
+				paths = f.Paths()
+			}
+			for _, path := range paths {
+				src := f
+				src.Path = path
+				src.Links = nil
+				var target string
+				switch p.Action.Type {
+				case ActionMove, ActionCopy:
+					target = p.Action.DestinationDir
+				case ActionRename:
+					target = path + p.Action.RenameSuffix
+				default:
+					target = ""
+				}
+				plan = append(plan, PlanItem{
+					GroupID: g.GroupID,
+					Source:  src,
+					Target:  target,
+					Action:  p.Action.Type,
+				})
+			}
+		}
+	}
+	return plan
 }
 
+func isDirGroup(g DuplicateGroup) bool {
+	return g.Kind == MatchDirectory || g.Kind == MatchDirSubset
+}
+
+// planDirs is what the directory groups of a plan do to the files below them. It is empty
+// unless the action removes files.
+type planDirs struct {
+	kept    []string        // directories a directory group keeps
+	removed []string        // directories a directory group deletes or moves
+	spared  map[string]bool // removed directories left in place because they hold a last copy
+}
+
+func newPlanDirs(groups []DuplicateGroup, action ActionType) *planDirs {
+	d := &planDirs{spared: map[string]bool{}}
+	if action != ActionDelete && action != ActionRecycle && action != ActionMove {
+		return d
+	}
+	removed := map[string]bool{}
+	for _, g := range groups {
+		if isDirGroup(g) && len(g.Files) > 1 {
+			for _, f := range g.Files[1:] {
+				removed[f.Path] = true
+				d.removed = append(d.removed, f.Path)
+			}
+		}
+	}
+	// a directory kept by one group and removed by another goes
+	for _, g := range groups {
+		if isDirGroup(g) && len(g.Files) > 1 && !removed[g.Files[0].Path] {
+			d.kept = append(d.kept, g.Files[0].Path)
+		}
+	}
+	return d
+}
+
+// holding returns the directories of dirs that one of the paths of f lies in.
+func holding(f FileInfo, dirs []string) []string {
+	var out []string
+	for _, dir := range dirs {
+		for _, p := range f.Paths() {
+			if nested(p, dir) {
+				out = append(out, dir)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// removes reports whether a removed directory that is not spared takes f with it.
+func (d *planDirs) removes(f FileInfo) bool {
+	for _, dir := range holding(f, d.removed) {
+		if !d.spared[dir] {
+			return true
+		}
+	}
+	return false
+}
 
+// keeper picks the copy a file group keeps: one inside a kept directory, which stays anyway,
+// else the first outside every removed directory. When every copy lies in a removed directory
+// the first is kept and the directories holding it are spared.
+func (d *planDirs) keeper(g DuplicateGroup) int {
+	first := -1
+	for i, f := range g.Files {
+		if d.removes(f) {
+			continue
+		}
+		if len(holding(f, d.kept)) > 0 {
+			return i
+		}
+		if first < 0 {
+			first = i
+		}
+	}
+	if first >= 0 || len(g.Files) == 0 {
+		return first
+	}
+	for _, dir := range holding(g.Files[0], d.removed) {
+		d.spared[dir] = true
+	}
+	return 0
+}
+
+// decides reports whether a directory group settles f: files in kept or spared directories
+// stay, files in removed ones go with their directory.
+func (d *planDirs) decides(f FileInfo) bool {
+	return len(holding(f, d.kept)) > 0 || len(holding(f, d.removed)) > 0
+}
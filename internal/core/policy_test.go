@@ -0,0 +1,149 @@
+package core
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestBuildPlanKeepsACopyAcrossDirectoryGroups(t *testing.T) {
+	content := map[string]string{"x": "content of x", "y": "content of y", "z": "content of z"}
+	tests := []struct {
+		name  string
+		files map[string]string // path -> content key
+		want  []string          // plan sources, sorted
+	}{
+		{
+			// the x group sorts photos/2019/x first, the subset directory the plan removes
+			name: "subset",
+			files: map[string]string{
+				"photos/all/x": "x", "photos/all/y": "y", "photos/all/z": "z",
+				"photos/2019/x": "x", "photos/2019/y": "y",
+				"backup/x": "x",
+			},
+			want: []string{"backup/x", "photos/2019"},
+		},
+		{
+			// a/b.old/x sorts before a/b/x, but a/b is the directory kept
+			name: "identical",
+			files: map[string]string{
+				"a/b/x": "x", "a/b/y": "y",
+				"a/b.old/x": "x", "a/b.old/y": "y",
+				"c/x": "x",
+			},
+			want: []string{"a/b.old", "c/x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{}
+			for p, key := range tt.files {
+				files[p] = content[key]
+			}
+			groups, _ := scanFS(t, ExactEngine{}, textFS(files), ScanConfig{DetectDirectories: true})
+			plan := BuildPlan(groups, Policy{Action: Action{Type: ActionDelete}})
+			var got []string
+			for _, it := range plan {
+				got = append(got, filepath.ToSlash(it.Source.Path))
+			}
+			sort.Strings(got)
+			if !equalStrings(got, tt.want) {
+				t.Fatalf("plan removes %v, want %v", got, tt.want)
+			}
+			// every content keeps a copy the plan leaves in place
+			left := map[string]bool{}
+			for p, key := range tt.files {
+				gone := false
+				for _, src := range got {
+					gone = gone || p == src || nested(p, src)
+				}
+				if !gone {
+					left[key] = true
+				}
+			}
+			for key := range content {
+				if !left[key] && hasContent(tt.files, key) {
+					t.Errorf("no copy of %s survives the plan", key)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildPlanSparesADirectoryHoldingTheLastCopy(t *testing.T) {
+	dir := func(p string) FileInfo { return FileInfo{Path: p, IsDir: true} }
+	groups := []DuplicateGroup{
+		{GroupID: "d1", Kind: MatchDirSubset, Files: []FileInfo{dir("/keep"), dir("/old")}},
+		{GroupID: "d2", Kind: MatchDirSubset, Files: []FileInfo{dir("/other"), dir("/keep")}},
+		{GroupID: "f", Kind: MatchExact, Files: []FileInfo{{Path: "/keep/x"}, {Path: "/old/x"}}},
+	}
+	plan := BuildPlan(groups, Policy{Action: Action{Type: ActionDelete}})
+	var got []string
+	for _, it := range plan {
+		got = append(got, it.Source.Path)
+	}
+	sort.Strings(got)
+	if want := []string{"/old"}; !equalStrings(got, want) {
+		t.Fatalf("plan removes %v, want %v", got, want)
+	}
+}
+
+func TestBuildPlanSkipsNonDuplicates(t *testing.T) {
+	groups := []DuplicateGroup{
+		{Kind: MatchPrefix, Files: []FileInfo{{Path: "a"}, {Path: "b"}}},
+		{Kind: MatchHardlink, Files: []FileInfo{{Path: "c", Links: []string{"d"}}}},
+		{Kind: MatchSymlink, Files: []FileInfo{{Path: "e", LinkTarget: "f"}}},
+		{Kind: MatchExact, Files: []FileInfo{{Path: "g"}, {Path: "h", Links: []string{"i"}}}},
+	}
+	tests := []struct {
+		action ActionType
+		want   []string
+	}{
+		{ActionDelete, []string{"h", "i"}},
+		{ActionRecycle, []string{"h", "i"}},
+		{ActionMove, []string{"h", "i"}},
+		{ActionCopy, []string{"h"}},
+		{ActionRename, []string{"h"}},
+		{ActionMark, []string{"h"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, it := range BuildPlan(groups, Policy{Action: Action{Type: tt.action, DestinationDir: "/dest", RenameSuffix: ".dup"}}) {
+			got = append(got, it.Source.Path)
+			want := ""
+			switch tt.action {
+			case ActionMove, ActionCopy:
+				want = "/dest"
+			case ActionRename:
+				want = it.Source.Path + ".dup"
+			}
+			if it.Target != want || it.Action != tt.action {
+				t.Errorf("%s: %s to %q by %s, want %q", tt.action, it.Source.Path, it.Target, it.Action, want)
+			}
+		}
+		if !equalStrings(got, tt.want) {
+			t.Errorf("%s: plan %v, want %v", tt.action, got, tt.want)
+		}
+	}
+}
+
+func hasContent(files map[string]string, key string) bool {
+	for _, k := range files {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+package core
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// limits bounds disk reads and hash computation independently so that a few fast disks can be
+// kept busy while CPU-heavy hashers do not oversubscribe the machine. In per-device mode reads
+// are additionally capped per device, so two roots on one spinning disk do not seek-thrash.
+type limits struct {
+	ctx     context.Context
+	pause   *Pauser
+	rep     *ScanReport
+	src     source
+	prog    *progressTracker
+	workers int
+	io      chan struct{}
+	cpu     chan struct{}
+
+	perDevice int
+	mu        sync.Mutex
+	devices   map[uint64]chan struct{}
+}
+
+func newLimits(ctx context.Context, config ScanConfig, rep *ScanReport) *limits {
+	workers := config.Concurrency
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	ioN := config.IOConcurrency
+	if ioN <= 0 {
+		ioN = workers
+	}
+	cpuN := config.HashConcurrency
+	if cpuN <= 0 {
+		cpuN = workers
+	}
+	// enough workers that reads of one file overlap hashing of another
+	if ioN+cpuN > workers {
+		workers = ioN + cpuN
+	}
+	return &limits{
+		ctx:       ctx,
+		pause:     config.Pauser,
+		rep:       rep,
+		src:       source{fsys: config.FS},
+		prog:      newProgressTracker(config.OnProgress),
+		workers:   workers,
+		io:        make(chan struct{}, ioN),
+		cpu:       make(chan struct{}, cpuN),
+		perDevice: config.PerDeviceIO,
+		devices:   map[uint64]chan struct{}{},
+	}
+}
+
+func (l *limits) acquireIO(dev uint64) {
+	if l.perDevice > 0 {
+		l.mu.Lock()
+		sem, ok := l.devices[dev]
+		if !ok {
+			sem = make(chan struct{}, l.perDevice)
+			l.devices[dev] = sem
+		}
+		l.mu.Unlock()
+		sem <- struct{}{}
+	}
+	l.io <- struct{}{}
+}
+
+func (l *limits) releaseIO(dev uint64) {
+	<-l.io
+	if l.perDevice > 0 {
+		l.mu.Lock()
+		sem := l.devices[dev]
+		l.mu.Unlock()
+		<-sem
+	}
+}
+
+// checkpoint blocks while the scan is paused and reports cancellation.
+func (l *limits) checkpoint() error { return l.pause.Wait(l.ctx) }
+
+// forEach runs fn for every index in [0,n) on the worker pool and waits for completion.
+// Once the scan is cancelled the remaining indexes are skipped.
+func (l *limits) forEach(n int, fn func(i int)) {
+	workers := l.workers
+	if workers > n {
+		workers = n
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if l.checkpoint() != nil {
+					continue
+				}
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// hashChunkSize is the read unit handed from the reader to the hasher.
+const hashChunkSize = 1 << 20
+
+// hashFile computes the full hash of a file. The reader holds the device's I/O slot for the whole
+// file to keep access sequential, while the hasher takes a CPU slot per chunk so reading the next
+// chunk overlaps hashing the previous one.
+func (l *limits) hashFile(alg Hasher, f FileInfo) (string, error) {
+	l.acquireIO(f.Device)
+	defer l.releaseIO(f.Device)
+	file, err := l.src.openContent(f)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := alg.New()
+	chunks := make(chan []byte, 2)
+	free := make(chan []byte, 3)
+	for i := 0; i < cap(free); i++ {
+		free <- make([]byte, hashChunkSize)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for b := range chunks {
+			l.cpu <- struct{}{}
+			h.Write(b)
+			<-l.cpu
+			free <- b[:cap(b)]
+		}
+	}()
+	var readErr error
+	for {
+		if err := l.checkpoint(); err != nil {
+			readErr = err
+			break
+		}
+		buf := <-free
+		n, err := io.ReadFull(file, buf)
+		if n > 0 {
+			chunks <- buf[:n]
+			l.prog.advance(f.Path, int64(n))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+	close(chunks)
+	<-done
+	if readErr != nil {
+		return "", readErr
+	}
+	return hexSum(h), nil
+}
+
+// sampleFile computes the head/tail sample hash under the I/O and CPU limits.
+func (l *limits) sampleFile(alg Hasher, f *FileInfo) (string, error) {
+	if f.Archive != "" {
+		return l.sampleMember(alg, f)
+	}
+	l.acquireIO(f.Device)
+	data, err := readSample(l.src, f.Path, f.SizeBytes)
+	l.releaseIO(f.Device)
+	if err != nil {
+		return "", err
+	}
+	if f.Type == "" {
+		// the head is at hand, so the type comes for free
+		f.Type, f.Class = sniffType(sniffHead(data), f.Path)
+	}
+	l.cpu <- struct{}{}
+	defer func() { <-l.cpu }()
+	h := alg.New()
+	h.Write(data)
+	return hexSum(h), nil
+}
+
+// sameContent compares two files byte by byte; the comparison counts as one read on a's device.
+func (l *limits) sameContent(a, b FileInfo) (bool, error) {
+	l.acquireIO(a.Device)
+	defer l.releaseIO(a.Device)
+	return sameContent(l.src, a, b)
+}
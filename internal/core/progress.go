@@ -0,0 +1,142 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is how often a running scan reports progress between stage changes.
+const progressInterval = 250 * time.Millisecond
+
+// progressTracker collects scan telemetry from the workers. Each stage that reads content
+// announces the bytes it expects to read; workers count the bytes they get through, which
+// yields a stage percentage, a throughput and an ETA.
+type progressTracker struct {
+	files      atomic.Int64
+	bytesFound atomic.Int64
+	groups     atomic.Int64
+	done       atomic.Int64 // bytes processed in the current stage
+	total      atomic.Int64 // bytes the current stage expects to process
+	path       atomic.Value // string, file being read
+
+	mu    sync.Mutex
+	stage string
+	start time.Time // start of the current stage's work
+
+	emitMu sync.Mutex
+	fn     func(Progress)
+}
+
+func newProgressTracker(fn func(Progress)) *progressTracker {
+	p := &progressTracker{stage: "walking", start: time.Now(), fn: fn}
+	p.path.Store("")
+	return p
+}
+
+// enter switches to stage, resets the stage counters to a workload of total bytes and reports.
+func (p *progressTracker) enter(stage string, total int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.stage = stage
+	p.start = time.Now()
+	p.mu.Unlock()
+	p.done.Store(0)
+	p.total.Store(total)
+	p.emit()
+}
+
+// grow adds work discovered while the stage runs, such as the full hashes of sample collisions.
+func (p *progressTracker) grow(n int64) {
+	if p != nil {
+		p.total.Add(n)
+	}
+}
+
+// advance counts n processed bytes of path.
+func (p *progressTracker) advance(path string, n int64) {
+	if p == nil {
+		return
+	}
+	p.path.Store(path)
+	p.done.Add(n)
+}
+
+// found counts a discovered file.
+func (p *progressTracker) found(path string, size int64) {
+	if p == nil {
+		return
+	}
+	p.path.Store(path)
+	p.files.Add(1)
+	p.bytesFound.Add(size)
+}
+
+func (p *progressTracker) snapshot() Progress {
+	p.mu.Lock()
+	stage, start := p.stage, p.start
+	p.mu.Unlock()
+	pr := Progress{
+		Stage:        stage,
+		FilesScanned: int(p.files.Load()),
+		GroupsFound:  int(p.groups.Load()),
+		BytesFound:   p.bytesFound.Load(),
+		BytesHashed:  p.done.Load(),
+		BytesToHash:  p.total.Load(),
+		CurrentPath:  p.path.Load().(string),
+		StagePercent: -1,
+	}
+	if pr.BytesToHash > 0 {
+		pr.StagePercent = 100 * float64(pr.BytesHashed) / float64(pr.BytesToHash)
+		if pr.StagePercent > 100 {
+			pr.StagePercent = 100
+		}
+	}
+	if secs := time.Since(start).Seconds(); secs > 0 && pr.BytesHashed > 0 {
+		pr.BytesPerSecond = float64(pr.BytesHashed) / secs
+		if left := pr.BytesToHash - pr.BytesHashed; left > 0 {
+			pr.ETA = time.Duration(float64(left) / pr.BytesPerSecond * float64(time.Second))
+		}
+	}
+	return pr
+}
+
+// emit passes a snapshot to the callback; calls never overlap.
+func (p *progressTracker) emit() {
+	if p == nil || p.fn == nil {
+		return
+	}
+	pr := p.snapshot()
+	p.emitMu.Lock()
+	defer p.emitMu.Unlock()
+	p.fn(pr)
+}
+
+// run emits a snapshot every progressInterval until the returned stop is called.
+func (p *progressTracker) run() (stop func()) {
+	if p == nil || p.fn == nil {
+		return func() {}
+	}
+	quit := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(progressInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-t.C:
+				p.emit()
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		wg.Wait()
+	}
+}
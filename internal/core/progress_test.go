@@ -0,0 +1,84 @@
+package core
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestProgressTracker(t *testing.T) {
+	p := newProgressTracker(nil)
+	p.found("a", 30)
+	p.found("b", 70)
+	if pr := p.snapshot(); pr.FilesScanned != 2 || pr.BytesFound != 100 || pr.StagePercent != -1 || pr.CurrentPath != "b" {
+		t.Errorf("after the walk: %+v", pr)
+	}
+	p.enter("hashing", 100)
+	p.advance("a", 30)
+	if pr := p.snapshot(); pr.BytesHashed != 30 || pr.BytesToHash != 100 || pr.StagePercent != 30 {
+		t.Errorf("hashing: %+v", pr)
+	}
+	p.grow(100)
+	if pr := p.snapshot(); pr.BytesToHash != 200 || pr.StagePercent != 15 {
+		t.Errorf("after growing: %+v", pr)
+	}
+	p.advance("b", 250)
+	if pr := p.snapshot(); pr.StagePercent != 100 || pr.ETA != 0 {
+		t.Errorf("overrun: %+v", pr)
+	}
+	p.enter("grouping", 0)
+	if pr := p.snapshot(); pr.Stage != "grouping" || pr.BytesHashed != 0 || pr.StagePercent != -1 || pr.FilesScanned != 2 {
+		t.Errorf("next stage: %+v", pr)
+	}
+
+	var nilTracker *progressTracker
+	nilTracker.found("a", 1)
+	nilTracker.enter("hashing", 1)
+	nilTracker.advance("a", 1)
+	nilTracker.grow(1)
+	nilTracker.run()()
+}
+
+func TestProgressTotals(t *testing.T) {
+	files := map[string]string{"a": large('1'), "b": large('1'), "c": large('2'), "d": "x", "e": "y"}
+	r := walkFS(t, textFS(files), ScanConfig{}, walkOptions{})
+	alg, err := LookupHasher("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr := r.lim.prog.snapshot(); pr.FilesScanned != 5 || pr.BytesFound != 9*sampleBytes+2 {
+		t.Errorf("walked %d files of %d bytes", pr.FilesScanned, pr.BytesFound)
+	}
+	// the large files share their samples, so the last group is emitted once every file is read
+	var last Progress
+	groupExact(r.files, alg, r.lim, nil, false, func(DuplicateGroup) { last = r.lim.prog.snapshot() })
+	want := int64(3*2*sampleBytes + 3*3*sampleBytes + 2) // samples and full hashes of the large files, the small files
+	if last.Stage != "hashing" || last.BytesHashed != want || last.BytesToHash != want || last.StagePercent != 100 {
+		t.Errorf("hashed %d of %d bytes in %s, want %d", last.BytesHashed, last.BytesToHash, last.Stage, want)
+	}
+}
+
+func TestScanProgress(t *testing.T) {
+	var mu sync.Mutex
+	var stages []string
+	var final Progress
+	config := ScanConfig{OnProgress: func(pr Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		if n := len(stages); n == 0 || stages[n-1] != pr.Stage {
+			stages = append(stages, pr.Stage)
+		}
+		if pr.BytesToHash > 0 && pr.BytesHashed > pr.BytesToHash {
+			t.Errorf("%s: hashed %d of %d bytes", pr.Stage, pr.BytesHashed, pr.BytesToHash)
+		}
+		final = pr
+	}}
+	groups, _ := scanFS(t, ExactEngine{}, textFS(map[string]string{"a": "same", "b": "same", "c": "else"}), config)
+	mu.Lock()
+	defer mu.Unlock()
+	if !equalStrings(stages, []string{"walking", "hashing", "grouping", "done"}) {
+		t.Errorf("stages %q", stages)
+	}
+	if final.FilesScanned != 3 || final.BytesFound != 12 || final.GroupsFound != len(groups) {
+		t.Errorf("final progress %+v", final)
+	}
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os/exec"
+	"sort"
+	"sync"
+)
+
+// ErrorCategory classifies why a path could not be checked.
+type ErrorCategory string
+
+const (
+	ErrorPermission ErrorCategory = "permission" // access denied
+	ErrorIO         ErrorCategory = "io"         // missing file, read failure, device error
+	ErrorDecode     ErrorCategory = "decode"     // image could not be decoded
+	ErrorFFmpeg     ErrorCategory = "ffmpeg"     // frame extraction failed
+	ErrorTooLarge   ErrorCategory = "too-large"  // larger than the mode reads, skipped
+)
+
+// ScanError records one path that was skipped or only partially checked.
+type ScanError struct {
+	Path     string
+	Stage    string // walking | hashing | verifying | media | text | cache
+	Category ErrorCategory
+	Message  string
+}
+
+// ScanReport collects the problems met during a scan, so callers know what was not checked.
+// It is safe for concurrent use; a nil *ScanReport discards everything.
+type ScanReport struct {
+	mu     sync.Mutex
+	Errors []ScanError
+}
+
+// add records err for path. Cancellation is not a per-path problem and is ignored.
+func (r *ScanReport) add(path, stage string, category ErrorCategory, err error) {
+	if r == nil || err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	r.mu.Lock()
+	r.Errors = append(r.Errors, ScanError{Path: path, Stage: stage, Category: category, Message: err.Error()})
+	r.mu.Unlock()
+}
+
+// addIO records a filesystem error, classifying permission problems separately.
+func (r *ScanReport) addIO(path, stage string, err error) {
+	r.add(path, stage, classifyIOError(err), err)
+}
+
+// addMedia records a media failure: filesystem errors keep their class, anything else is a decode
+// error, or an ffmpeg error when the frame extractor failed.
+func (r *ScanReport) addMedia(path string, err error, ffmpeg bool) {
+	var pe *fs.PathError
+	var ee *exec.Error
+	var xe *exec.ExitError
+	switch {
+	case ffmpeg && (errors.As(err, &ee) || errors.As(err, &xe)):
+		r.add(path, "media", ErrorFFmpeg, err)
+	case errors.As(err, &pe):
+		r.addIO(path, "media", err)
+	default:
+		r.add(path, "media", ErrorDecode, err)
+	}
+}
+
+// Count returns the number of recorded errors.
+func (r *ScanReport) Count() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.Errors)
+}
+
+// ByCategory returns error counts per category.
+func (r *ScanReport) ByCategory() map[ErrorCategory]int {
+	out := map[ErrorCategory]int{}
+	if r == nil {
+		return out
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.Errors {
+		out[e.Category]++
+	}
+	return out
+}
+
+// Sorted returns a copy of the errors ordered by path, then stage.
+func (r *ScanReport) Sorted() []ScanError {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	out := append([]ScanError(nil), r.Errors...)
+	r.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Stage < out[j].Stage
+	})
+	return out
+}
+
+func classifyIOError(err error) ErrorCategory {
+	if errors.Is(err, fs.ErrPermission) {
+		return ErrorPermission
+	}
+	return ErrorIO
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// errNotOnDisk is reported for work that needs a real file, such as running ffmpeg, when the
+// scan reads from an fs.FS.
+var errNotOnDisk = errors.New("file is not on the OS filesystem")
+
+// source is the filesystem a scan reads: the OS one, or ScanConfig.FS. Paths inside the scan
+// always use the OS separator, so path handling is the same for both; they are converted to
+// the slash-separated form fs.FS expects only when the FS is called.
+type source struct {
+	fsys fs.FS     // nil for the OS filesystem
+	zips *zipCache // zip archives open for reading members; nil opens one for each member
+}
+
+func (s source) isOS() bool { return s.fsys == nil }
+
+// fsName converts a scan path to an fs.FS name.
+func (s source) fsName(name string) string {
+	return path.Clean(filepath.ToSlash(name))
+}
+
+func (s source) open(name string) (fs.File, error) {
+	if s.fsys == nil {
+		return os.Open(name)
+	}
+	return s.fsys.Open(s.fsName(name))
+}
+
+func (s source) stat(name string) (fs.FileInfo, error) {
+	if s.fsys == nil {
+		return os.Stat(name)
+	}
+	return fs.Stat(s.fsys, s.fsName(name))
+}
+
+func (s source) readDir(name string) ([]fs.DirEntry, error) {
+	if s.fsys == nil {
+		return os.ReadDir(name)
+	}
+	return fs.ReadDir(s.fsys, s.fsName(name))
+}
+
+// walk is filepath.WalkDir over the source; fn sees paths with the OS separator.
+func (s source) walk(root string, fn fs.WalkDirFunc) error {
+	if s.fsys == nil {
+		return filepath.WalkDir(root, fn)
+	}
+	return fs.WalkDir(s.fsys, s.fsName(root), func(p string, d fs.DirEntry, err error) error {
+		return fn(filepath.FromSlash(p), d, err)
+	})
+}
+
+// readerAt returns f as an io.ReaderAt with its size. Files that cannot seek are read into
+// memory; os.File and the common fs.FS implementations can.
+func readerAt(f fs.File) (io.ReaderAt, int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	if ra, ok := f.(io.ReaderAt); ok {
+		return ra, info.Size(), nil
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(data), int64(len(data)), nil
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// textFS builds a filesystem of the given paths and contents.
+func textFS(files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for p, data := range files {
+		fsys[p] = &fstest.MapFile{Data: []byte(data)}
+	}
+	return fsys
+}
+
+// large returns 3*sampleBytes of content whose head and tail windows are the same for every
+// middle byte, so files made from it collide on their samples and differ in full.
+func large(middle byte) string {
+	b := bytes.Repeat([]byte{'x'}, int(3*sampleBytes))
+	b[len(b)/2] = middle
+	return string(b)
+}
+
+// walkFS starts a scan of fsys and walks it; the run is closed when the test ends.
+func walkFS(t *testing.T, fsys fs.FS, config ScanConfig, opts walkOptions) *scanRun {
+	t.Helper()
+	config.FS = fsys
+	r, err := newScanRun(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.close)
+	r.walk(opts)
+	return r
+}
+
+// walked lists the paths a walk collected, in / form.
+func walked(r *scanRun) []string {
+	var out []string
+	for _, f := range r.files {
+		out = append(out, filepath.ToSlash(f.Path))
+	}
+	return out
+}
+
+// scanFS runs engine over fsys and fails the test if the scan does.
+func scanFS(t *testing.T, engine ScannerEngine, fsys fs.FS, config ScanConfig) ([]DuplicateGroup, *ScanReport) {
+	t.Helper()
+	config.FS = fsys
+	groups, rep, err := engine.Scan(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return groups, rep
+}
+
+// describe writes each group as its kind and member paths, "exact a b".
+func describe(groups []DuplicateGroup) []string {
+	out := make([]string, 0, len(groups))
+	for _, g := range groups {
+		parts := []string{string(g.Kind)}
+		for _, f := range g.Files {
+			parts = append(parts, filepath.ToSlash(f.Path))
+		}
+		out = append(out, strings.Join(parts, " "))
+	}
+	return out
+}
+
+// checkGroups reports groups that differ from want, written as describe writes them.
+func checkGroups(t *testing.T, groups []DuplicateGroup, want []string) {
+	t.Helper()
+	if got := describe(groups); !equalStrings(got, want) {
+		t.Errorf("groups %q, want %q", got, want)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestScanFS(t *testing.T) {
+	fsys := textFS(map[string]string{"a/x": "same", "a/y": "same", "b/z": "same", "b/w": "else"})
+	tests := []struct {
+		name   string
+		roots  []string
+		want   []string
+		errors int
+	}{
+		{"whole filesystem", nil, []string{"exact a/x a/y b/z"}, 0},
+		{"root by name", []string{"."}, []string{"exact a/x a/y b/z"}, 0},
+		{"one directory", []string{"a"}, []string{"exact a/x a/y"}, 0},
+		{"unclean names", []string{"./a/", "b//"}, []string{"exact a/x a/y b/z"}, 0},
+		{"missing directory", []string{"a", "nope"}, []string{"exact a/x a/y"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, rep := scanFS(t, ExactEngine{}, fsys, ScanConfig{IncludePaths: tt.roots})
+			checkGroups(t, groups, tt.want)
+			if rep.Count() != tt.errors {
+				t.Errorf("errors %v, want %d", rep.Errors, tt.errors)
+			}
+		})
+	}
+}
+
+func TestScanFSLeavesTheDiskAlone(t *testing.T) {
+	// the hash index and link policies refer to OS paths, so an fs.FS scan does not use them
+	idx, err := OpenHashIndex(filepath.Join(t.TempDir(), "index.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := textFS(map[string]string{"x": "same", "y": "same"})
+	groups, _ := scanFS(t, ExactEngine{}, fsys, ScanConfig{HashIndex: idx, SymlinkPolicy: SymlinksFollow})
+	checkGroups(t, groups, []string{"exact x y"})
+	if n := idx.Stats().Entries; n != 0 {
+		t.Errorf("the index holds %d entries", n)
+	}
+}
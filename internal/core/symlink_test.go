@@ -0,0 +1,69 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func symlink(t *testing.T, oldname, newname string) {
+	t.Helper()
+	if err := os.Symlink(oldname, newname); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+}
+
+func TestSymlinkPolicies(t *testing.T) {
+	out := t.TempDir()
+	if err := os.WriteFile(filepath.Join(out, "c"), []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := diskTree(t, map[string]string{"a": "content", "sub/b": "content"})
+	symlink(t, "a", "file-link")
+	symlink(t, out, "outside")
+	symlink(t, "..", filepath.Join("sub", "loop"))
+	symlink(t, dir, filepath.Join(out, "back")) // a loop through the outside directory
+
+	tests := []struct {
+		policy SymlinkPolicy
+		want   []string
+		links  []string // other paths of a
+	}{
+		{
+			policy: SymlinksIgnore,
+			want:   []string{"exact a sub/b"},
+		},
+		{
+			policy: SymlinksFollow,
+			want:   []string{"exact " + filepath.ToSlash(filepath.Join(out, "c")) + " a sub/b"},
+			links:  []string{"file-link"},
+		},
+		{
+			policy: SymlinksReport,
+			want:   []string{"exact a sub/b", "symlink file-link a", "symlink outside " + filepath.ToSlash(out), "symlink sub/loop ."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			groups, rep, err := (ExactEngine{}).Scan(ScanConfig{IncludePaths: []string{"."}, SymlinkPolicy: tt.policy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGroups(t, groups, tt.want)
+			if rep.Count() != 0 {
+				t.Errorf("errors %v", rep.Errors)
+			}
+			for _, f := range groups[0].Files {
+				if f.Path == "a" && !equalStrings(f.Links, tt.links) {
+					t.Errorf("a is also %q, want %q", f.Links, tt.links)
+				}
+			}
+		})
+	}
+}
+
+func TestSymlinkPolicyIsChecked(t *testing.T) {
+	if _, _, err := (ExactEngine{}).Scan(ScanConfig{IncludePaths: []string{t.TempDir()}, SymlinkPolicy: "FOLLOW"}); err == nil {
+		t.Error("no error for an unknown policy")
+	}
+}
@@ -0,0 +1,268 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultWatchDebounce is the quiet period Watch waits for when WatchConfig.Debounce is zero.
+const DefaultWatchDebounce = 2 * time.Second
+
+// WatchConfig configures Watch.
+type WatchConfig struct {
+	// Scan selects what is watched and how files are compared, as for a scan in an exact mode.
+	// Scan.HashIndex should be set: it is kept current as files change, so rescans only read
+	// new content. Scan.OnGroup is not called.
+	Scan ScanConfig
+	// Debounce is how long the watched trees must stay quiet before changes are checked, so
+	// files still being copied are not compared half written. Zero means DefaultWatchDebounce.
+	Debounce time.Duration
+	// Policy, when set, is applied to new duplicates: the copy that was there first is kept and
+	// the new files are acted on. Every batch of actions is saved with PersistExecLog.
+	Policy *Policy
+	Exec   ExecuteOptions // DryRun here or in Policy.Action previews only
+	// OnEvent receives the result of the initial scan and of every rescan that found new
+	// duplicates or failed. Calls never overlap.
+	OnEvent func(WatchEvent)
+}
+
+// WatchEvent reports one scan of the watched trees.
+type WatchEvent struct {
+	Time    time.Time
+	Initial bool // the scan that established which files already exist
+	Files   int  // files compared
+	Groups  []WatchGroup
+	Report  *ScanReport
+	Exec    *ExecResult // actions taken on Groups, when a Policy is set
+	LogPath string      // where Exec was saved
+	Err     error
+}
+
+// WatchGroup is a set of identical files that gained members since the previous scan.
+type WatchGroup struct {
+	Group DuplicateGroup // every copy, the ones that were there before first
+	New   []FileInfo     // files that appeared or changed since the previous scan
+}
+
+// fileStamp is the metadata by which a rescan tells a file it has seen before from a new one.
+type fileStamp struct {
+	size, modNano int64
+	inode         uint64
+}
+
+func stampOf(f FileInfo) fileStamp { return fileStamp{f.SizeBytes, f.modNano, f.Inode} }
+
+// watcher is the state Watch carries between rescans.
+type watcher struct {
+	cfg     WatchConfig
+	fsw     *fsnotify.Watcher
+	watched map[string]bool      // directories registered with fsw
+	known   map[string]fileStamp // files of the previous scan
+}
+
+// Watch monitors the include paths of cfg.Scan and reports files that duplicate ones already
+// there. After an initial scan, every change to the trees starts the debounce timer; once they
+// are quiet, the trees are walked again. Only files of a size that gained a new or changed file
+// are compared, and hashes of unchanged files come from the hash index, so a rescan costs little
+// more than the walk. Directories are watched as the walk finds them, including new ones.
+// Watch blocks until ctx is cancelled and then returns ctx.Err().
+func Watch(ctx context.Context, cfg WatchConfig) error {
+	if cfg.Scan.FS != nil {
+		return errors.New("watch needs the OS filesystem")
+	}
+	info, err := LookupEngine(cfg.Scan.Mode)
+	if err != nil {
+		return err
+	}
+	if !info.Capabilities.Exact {
+		return fmt.Errorf("watch compares exact content; mode %q is not supported", info.Mode)
+	}
+	if cfg.Debounce <= 0 {
+		cfg.Debounce = DefaultWatchDebounce
+	}
+	if cfg.Policy != nil && cfg.Policy.Action.DryRun {
+		cfg.Exec.DryRun = true
+	}
+	cfg.Scan.OnGroup = nil
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer fsw.Close()
+	w := &watcher{cfg: cfg, fsw: fsw, watched: map[string]bool{}, known: map[string]fileStamp{}}
+	if err := w.rescan(ctx, true); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(cfg.Debounce)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-fsw.Events:
+			if !ok {
+				return ctx.Err()
+			}
+			if ev.Op == fsnotify.Chmod {
+				continue // attribute changes leave content alone
+			}
+			// every change restarts the quiet period
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(cfg.Debounce)
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return ctx.Err()
+			}
+			w.notify(WatchEvent{Time: time.Now(), Err: err})
+		case <-timer.C:
+			if err := w.rescan(ctx, false); err != nil {
+				w.notify(WatchEvent{Time: time.Now(), Err: err})
+			}
+		}
+	}
+}
+
+// rescan walks the trees, compares what is new against what was there and applies the policy.
+// Only configuration errors are returned; a cancelled rescan ends quietly.
+func (w *watcher) rescan(ctx context.Context, initial bool) error {
+	r, err := newScanRun(ctx, w.cfg.Scan)
+	if err != nil {
+		return err
+	}
+	defer r.close()
+	r.walk(walkOptions{archives: w.cfg.Scan.ScanArchives, dirs: true})
+	w.watchDirs(r.dirs)
+
+	// sizes that gained a file; on the initial scan nothing is new, everything is the baseline
+	sizes := map[int64]bool{}
+	current := make(map[string]fileStamp, len(r.files))
+	for _, f := range r.files {
+		current[f.Path] = stampOf(f)
+		if old, ok := w.known[f.Path]; !initial && (!ok || old != stampOf(f)) {
+			sizes[f.SizeBytes] = true
+		}
+	}
+	var candidates []FileInfo
+	for _, f := range r.files {
+		if sizes[f.SizeBytes] {
+			candidates = append(candidates, f)
+		}
+	}
+	verify := w.cfg.Scan.VerifyBytes || !r.alg.CollisionSafe
+	groups := groupExact(candidates, r.alg, r.lim, r.config.HashIndex, verify, func(DuplicateGroup) {})
+	// deleted files leave the index, so it describes the trees as they are
+	for p := range w.known {
+		if _, ok := current[p]; !ok && r.config.HashIndex != nil {
+			if _, err := os.Lstat(p); errors.Is(err, os.ErrNotExist) {
+				r.config.HashIndex.drop(p)
+			}
+		}
+	}
+	if _, _, err := r.done(groups); err != nil {
+		return nil // cancelled; the next scan picks up where this one left
+	}
+
+	ev := WatchEvent{Time: time.Now(), Initial: initial, Files: len(r.files), Report: r.rep}
+	for _, g := range groups {
+		if g.Kind != MatchExact {
+			continue
+		}
+		var before, added []FileInfo
+		for _, f := range g.Files {
+			if old, ok := w.known[f.Path]; ok && old == stampOf(f) {
+				before = append(before, f)
+			} else {
+				added = append(added, f)
+			}
+		}
+		if len(added) == 0 {
+			continue
+		}
+		g.Files = append(before, added...)
+		ev.Groups = append(ev.Groups, WatchGroup{Group: g, New: added})
+	}
+	w.known = current
+	if w.cfg.Policy != nil && len(ev.Groups) > 0 {
+		w.apply(&ev)
+	}
+	if initial || len(ev.Groups) > 0 {
+		w.notify(ev)
+	}
+	return nil
+}
+
+// apply runs the policy on the new files of ev.Groups and saves the audit log.
+func (w *watcher) apply(ev *WatchEvent) {
+	plans := make([]DuplicateGroup, 0, len(ev.Groups))
+	for _, wg := range ev.Groups {
+		// keep one copy that was there before, or the first new one when all are new
+		keep := wg.Group.Files[0]
+		act := wg.New
+		if keep.Path == act[0].Path {
+			act = act[1:]
+		}
+		plans = append(plans, DuplicateGroup{GroupID: wg.Group.GroupID, Kind: wg.Group.Kind, Files: append([]FileInfo{keep}, act...)})
+	}
+	res := Execute(BuildPlan(plans, *w.cfg.Policy), w.cfg.Exec)
+	ev.Exec = &res
+	if len(res.Entries) == 0 {
+		return
+	}
+	ev.LogPath, ev.Err = PersistExecLog(res)
+	if w.cfg.Exec.DryRun {
+		return
+	}
+	// files the actions created, such as renamed copies, are not new duplicates for the next scan
+	for _, e := range res.Entries {
+		if e.Status != "success" || e.Target == "" {
+			continue
+		}
+		if info, err := os.Lstat(e.Target); err == nil && info.Mode().IsRegular() {
+			_, ino := fileIdentity(e.Target, info)
+			w.known[e.Target] = fileStamp{info.Size(), info.ModTime().UnixNano(), ino}
+		}
+	}
+}
+
+// watchDirs registers the directories the walk visited; fsnotify drops removed ones itself.
+func (w *watcher) watchDirs(dirs []string) {
+	for _, d := range dirs {
+		if w.watched[d] {
+			continue
+		}
+		if err := w.fsw.Add(d); err != nil {
+			w.notify(WatchEvent{Time: time.Now(), Err: fmt.Errorf("watch %s: %w", d, err)})
+			continue
+		}
+		w.watched[d] = true
+	}
+	seen := make(map[string]bool, len(dirs))
+	for _, d := range dirs {
+		seen[d] = true
+	}
+	for d := range w.watched {
+		if !seen[d] {
+			_ = w.fsw.Remove(d)
+			delete(w.watched, d)
+		}
+	}
+}
+
+func (w *watcher) notify(ev WatchEvent) {
+	if w.cfg.OnEvent != nil {
+		w.cfg.OnEvent(ev)
+	}
+}
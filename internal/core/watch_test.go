@@ -0,0 +1,174 @@
+package core
+
+import (
+	"context"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// testWatcher watches "." as Watch would and collects the events it reports.
+func testWatcher(t *testing.T, policy *Policy) (*watcher, *[]WatchEvent) {
+	t.Helper()
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Skipf("fsnotify unavailable: %v", err)
+	}
+	t.Cleanup(func() { fsw.Close() })
+	var events []WatchEvent
+	cfg := WatchConfig{
+		Scan:    ScanConfig{IncludePaths: []string{"."}},
+		Policy:  policy,
+		Exec:    ExecuteOptions{ConflictPolicy: ConflictRename},
+		OnEvent: func(ev WatchEvent) { events = append(events, ev) },
+	}
+	return &watcher{cfg: cfg, fsw: fsw, watched: map[string]bool{}, known: map[string]fileStamp{}}, &events
+}
+
+// rewrite replaces the content of path and moves its modification time on, so a rescan sees
+// the change however coarse the filesystem's clock.
+func rewrite(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// groupsOf returns the groups of ev.
+func groupsOf(ev WatchEvent) []DuplicateGroup {
+	var out []DuplicateGroup
+	for _, g := range ev.Groups {
+		out = append(out, g.Group)
+	}
+	return out
+}
+
+// newFiles lists the paths of each group's new files.
+func newFiles(ev WatchEvent) [][]string {
+	var out [][]string
+	for _, g := range ev.Groups {
+		var paths []string
+		for _, f := range g.New {
+			paths = append(paths, f.Path)
+		}
+		out = append(out, paths)
+	}
+	return out
+}
+
+func TestWatchRescan(t *testing.T) {
+	diskTree(t, map[string]string{"a": "one", "b": "two", "sub/c": "three"})
+	w, events := testWatcher(t, nil)
+	ctx := context.Background()
+	rescan := func(initial bool) {
+		t.Helper()
+		*events = nil
+		if err := w.rescan(ctx, initial); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// existing copies are the baseline, not news
+	rewrite(t, "a2", "one")
+	rescan(true)
+	if len(*events) != 1 || !(*events)[0].Initial || (*events)[0].Files != 4 || len((*events)[0].Groups) != 0 {
+		t.Fatalf("initial events %+v", *events)
+	}
+	if !w.watched["."] || !w.watched["sub"] {
+		t.Errorf("watching %v", w.watched)
+	}
+
+	// a new copy is reported after the ones that were there
+	rewrite(t, "sub/d", "two")
+	rescan(false)
+	if len(*events) != 1 {
+		t.Fatalf("events %+v", *events)
+	}
+	checkGroups(t, groupsOf((*events)[0]), []string{"exact b sub/d"})
+
+	// nothing changed, nothing to report
+	rescan(false)
+	if len(*events) != 0 {
+		t.Errorf("events %+v", *events)
+	}
+
+	// a file changed into a copy is new, and unrelated new files are not compared
+	rewrite(t, "sub/c", "one")
+	rewrite(t, "e", "unique")
+	rescan(false)
+	if len(*events) != 1 {
+		t.Fatalf("events %+v", *events)
+	}
+	checkGroups(t, groupsOf((*events)[0]), []string{"exact a a2 sub/c"})
+	if got := newFiles((*events)[0]); len(got) != 1 || !equalStrings(got[0], []string{"sub/c"}) {
+		t.Errorf("new files %q", got)
+	}
+
+	// removed directories are no longer watched
+	if err := os.RemoveAll("sub"); err != nil {
+		t.Fatal(err)
+	}
+	rescan(false)
+	if w.watched["sub"] {
+		t.Error("sub still watched")
+	}
+}
+
+func TestWatchApply(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir()) // where PersistExecLog saves
+	diskTree(t, map[string]string{"a": "one"})
+	w, events := testWatcher(t, &Policy{Action: Action{Type: ActionRename, RenameSuffix: ".dup"}})
+	ctx := context.Background()
+	if err := w.rescan(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// two new copies: the one that was there is kept and both new ones are renamed
+	rewrite(t, "b", "one")
+	rewrite(t, "c", "one")
+	*events = nil
+	if err := w.rescan(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(*events) != 1 {
+		t.Fatalf("events %+v", *events)
+	}
+	ev := (*events)[0]
+	if ev.Exec == nil || len(ev.Exec.Entries) != 2 || ev.LogPath == "" || ev.Err != nil {
+		t.Fatalf("applied %+v, log %q, error %v", ev.Exec, ev.LogPath, ev.Err)
+	}
+	for _, e := range ev.Exec.Entries {
+		if e.Status != "success" || e.Source == "a" {
+			t.Errorf("%s %s: %s %s", e.Action, e.Source, e.Status, e.Message)
+		}
+	}
+	if _, err := os.Stat("a"); err != nil {
+		t.Errorf("the kept copy: %v", err)
+	}
+
+	// the renamed copies are the policy's own work, not new duplicates
+	*events = nil
+	if err := w.rescan(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(*events) != 0 {
+		t.Errorf("events %+v", *events)
+	}
+}
+
+func TestWatchRejectsUnsupportedConfigs(t *testing.T) {
+	ctx := context.Background()
+	if err := Watch(ctx, WatchConfig{Scan: ScanConfig{FS: fstest.MapFS{}}}); err == nil {
+		t.Error("no error for an fs.FS")
+	}
+	if err := Watch(ctx, WatchConfig{Scan: ScanConfig{Mode: "text"}}); err == nil {
+		t.Error("no error for a similarity mode")
+	}
+}
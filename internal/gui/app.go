@@ -1,9 +1,12 @@
 package gui
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"os"
 	"image/color"
+	"time"
 
 	"goduplicate/internal/core"
 
@@ -84,6 +87,10 @@ func init() {
 	ensureChineseFontSupport()
 }
 
+// resultsRefreshInterval is the least time between refreshes of the result pages while groups
+// stream in.
+const resultsRefreshInterval = 500 * time.Millisecond
+
 // Run starts the GUI application with placeholder pages matching requirements.
 func Run() {
 	// 首先确保中文显示支持
@@ -128,24 +135,68 @@ func Run() {
 
 	startScan := func(cfg core.ScanConfig) {
 		applyFFmpegEnv()
+		ctx, cancel := context.WithCancel(context.Background())
+		pauser := core.NewPauser()
+		cfg.Pauser = pauser
 		state.mu.Lock()
+		if state.IsScanning {
+			state.mu.Unlock()
+			cancel()
+			return
+		}
+		useCache := state.UseHashCache
 		state.IsScanning = true
+		state.ScanIncomplete = false
+		state.ScanCancel = cancel
+		state.ScanPauser = pauser
 		state.LastScanError = nil
+		state.LastReport = nil
 		state.FilesScanned = 0
 		state.GroupsFound = 0
+		state.Progress = core.Progress{}
+		state.Results = nil
 		state.mu.Unlock()
+		state.NotifyResultsChanged()
+		if useCache {
+			if idx, err := core.OpenHashIndex(core.DefaultHashIndexPath()); err == nil {
+				cfg.HashIndex = idx
+			}
+		}
 		cfg.OnProgress = func(p core.Progress) {
 			state.mu.Lock()
 			state.FilesScanned = p.FilesScanned
-			state.GroupsFound = p.GroupsFound
+			state.Progress = p
+			if p.GroupsFound > state.GroupsFound {
+				state.GroupsFound = p.GroupsFound
+			}
 			state.mu.Unlock()
 		}
+		// results fill in while the scan runs; the final list replaces them when it ends.
+		// Calls are serialized, so the throttle needs no lock.
+		var lastNotify time.Time
+		cfg.OnGroup = func(g core.DuplicateGroup) {
+			state.mu.Lock()
+			state.Results = append(state.Results, g)
+			state.GroupsFound = len(state.Results)
+			state.mu.Unlock()
+			if time.Since(lastNotify) >= resultsRefreshInterval {
+				lastNotify = time.Now()
+				state.NotifyResultsChanged()
+			}
+		}
 		go func() {
-			groups, err := engine.Scan(cfg)
+			groups, report, err := engine.ScanContext(ctx, cfg)
+			cancel()
 			state.mu.Lock()
 			state.IsScanning = false
-			state.LastScanError = err
+			state.ScanCancel = nil
+			state.ScanPauser = nil
+			state.ScanIncomplete = errors.Is(err, context.Canceled)
+			if !state.ScanIncomplete {
+				state.LastScanError = err
+			}
 			state.Results = groups
+			state.LastReport = report
 			state.GroupsFound = len(groups)
 			total := 0
 			for _, g := range groups {
@@ -155,6 +206,7 @@ func Run() {
 				state.FilesScanned = total
 			}
 			state.mu.Unlock()
+			state.NotifyResultsChanged()
 		}()
 	}
 
@@ -162,6 +214,7 @@ func Run() {
 		container.NewTabItem(t(state, "tab_config"), buildConfigPage(state, startScan)),
 		container.NewTabItem(t(state, "tab_monitor"), buildMonitorPage(state)),
 		container.NewTabItem(t(state, "tab_results"), buildResultsPage(state)),
+		container.NewTabItem(t(state, "tab_errors"), buildErrorsPage(state)),
 		container.NewTabItem(t(state, "tab_strategy"), buildStrategyPage(state, func(plan []core.PlanItem) {})),
 		container.NewTabItem(t(state, "tab_execute"), buildExecutePage(state)),
 		container.NewTabItem(t(state, "tab_settings"), buildSettingsPage(state)),
@@ -173,6 +226,7 @@ func Run() {
 			container.NewTabItem(t(state, "tab_config"), buildConfigPage(state, startScan)),
 			container.NewTabItem(t(state, "tab_monitor"), buildMonitorPage(state)),
 			container.NewTabItem(t(state, "tab_results"), buildResultsPage(state)),
+			container.NewTabItem(t(state, "tab_errors"), buildErrorsPage(state)),
 			container.NewTabItem(t(state, "tab_strategy"), buildStrategyPage(state, func(plan []core.PlanItem) {})),
 			container.NewTabItem(t(state, "tab_execute"), buildExecutePage(state)),
 			container.NewTabItem(t(state, "tab_settings"), buildSettingsPage(state)),
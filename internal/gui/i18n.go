@@ -17,16 +17,24 @@ var zhCN = map[string]string{
 	"label_groups":    "发现重复组:",
 	"label_speed":     "速度:",
 	"speed_unit":      "文件/秒",
+	"label_stage":     "阶段: %s",
+	"label_eta":       "剩余时间: %s",
+	"label_current":   "当前文件: %s",
+	"eta_unknown":     "-",
 	"placeholder_include": "示例: D;E docs",
-	"placeholder_exclude": "示例: *.tmp;node_modules;*.bak",
+	"placeholder_exclude": "示例: *.tmp;node_modules/;/build;!keep.bak",
+	"placeholder_include_patterns": "留空为全部，示例: *.jpg;photos/**/*.png",
 	"placeholder_min_size": "最小大小(字节)",
 	"placeholder_max_size": "最大大小(字节,0不限)",
 	"label_concurrency": "并发度: %d",
 	"label_similarity": "相似度阈值: %.2f",
 	"form_include_paths": "扫描路径(;)分隔",
 	"form_exclude_patterns": "排除模式(;)分隔",
+	"form_include_patterns": "包含模式(;)分隔",
 	"form_mode": "模式",
 	"form_hash_algorithm": "哈希算法",
+	"form_perceptual_hash": "感知哈希",
+	"form_clustering": "分组方式",
 	"form_min_size": "最小大小",
 	"form_max_size": "最大大小",
 	"form_concurrency": "并发度",
@@ -63,6 +71,53 @@ var zhCN = map[string]string{
 	"msg_select_group_for_details": "选择一个重复组以查看详情",
 	"msg_thumbnail_generation_failed": "缩略图生成失败",
 	"msg_generating": "生成中…",
+	"form_verify": "字节校验",
+	"check_verify_bytes": "哈希相同后逐字节比对",
+	"label_prefix_match": "仅头尾相同(非重复)",
+	"check_per_device_io": "同一磁盘串行读取(机械硬盘)",
+	"btn_pause": "暂停",
+	"btn_resume": "继续",
+	"btn_stop": "停止",
+	"status_paused": "状态: 已暂停",
+	"status_cancelled": "状态: 已停止(结果不完整)",
+	"form_hash_cache": "哈希缓存",
+	"check_hash_cache": "复用未变化文件的哈希",
+	"label_hash_cache": "哈希缓存",
+	"btn_cache_stats": "查看统计",
+	"btn_cache_prune": "清理失效条目",
+	"msg_cache_stats": "条目 %d | %d 字节 | %s",
+	"msg_cache_pruned": "已清理 %d 条",
+	"tab_errors": "扫描错误",
+	"msg_no_scan_errors": "上次扫描没有无法检查的文件",
+	"msg_scan_errors": "未能检查的路径: %d (%s)",
+	"label_hardlinked": "已是硬链接(同一份数据)",
+	"label_links": "另有 %d 个硬链接",
+	"form_symlinks": "符号链接",
+	"form_detect_dirs": "重复目录",
+	"check_detect_dirs": "检测相同目录和子集目录",
+	"form_archives": "压缩包",
+	"check_archives": "比对 zip/tar 内的文件",
+	"form_image_matching": "图片匹配",
+	"check_rotation_invariant": "识别旋转和镜像的副本",
+	"check_trim_borders": "忽略边框和黑边",
+	"label_dir_identical": "相同目录(含 %d 个文件组)",
+	"label_dir_subset": "子集目录(占 %.0f%%)",
+	"form_extensions": "扩展名",
+	"placeholder_ext": "仅包含，如 jpg;png",
+	"placeholder_exclude_ext": "排除，如 tmp;bak",
+	"form_modified": "修改时间",
+	"placeholder_modified_after": "晚于 YYYY-MM-DD",
+	"placeholder_modified_before": "早于 YYYY-MM-DD",
+	"form_type_classes": "文件类型(按内容识别)",
+	"class_image": "图片",
+	"class_video": "视频",
+	"class_audio": "音频",
+	"class_document": "文档",
+	"class_archive": "压缩包",
+	"class_code": "代码",
+	"form_attributes": "文件属性",
+	"check_skip_hidden": "跳过隐藏文件",
+	"check_skip_system": "跳过系统文件",
 }
 
 var enUS = map[string]string{
@@ -80,16 +135,24 @@ var enUS = map[string]string{
 	"label_groups":    "Duplicate groups:",
 	"label_speed":     "Speed:",
 	"speed_unit":      "files/sec",
+	"label_stage":     "Stage: %s",
+	"label_eta":       "Time left: %s",
+	"label_current":   "Current file: %s",
+	"eta_unknown":     "-",
 	"placeholder_include": "Example: D;E docs",
-	"placeholder_exclude": "Example: *.tmp;node_modules;*.bak",
+	"placeholder_exclude": "Example: *.tmp;node_modules/;/build;!keep.bak",
+	"placeholder_include_patterns": "Empty for all, e.g. *.jpg;photos/**/*.png",
 	"placeholder_min_size": "Min size(bytes)",
 	"placeholder_max_size": "Max size(bytes,0=unlimited)",
 	"label_concurrency": "Concurrency: %d",
 	"label_similarity": "Similarity threshold: %.2f",
 	"form_include_paths": "Include paths(; separated)",
 	"form_exclude_patterns": "Exclude patterns(; separated)",
+	"form_include_patterns": "Include patterns(; separated)",
 	"form_mode": "Mode",
 	"form_hash_algorithm": "Hash algorithm",
+	"form_perceptual_hash": "Perceptual hash",
+	"form_clustering": "Clustering",
 	"form_min_size": "Min size",
 	"form_max_size": "Max size",
 	"form_concurrency": "Concurrency",
@@ -126,6 +189,53 @@ var enUS = map[string]string{
 	"msg_select_group_for_details": "Select a group to view details",
 	"msg_thumbnail_generation_failed": "Thumbnail generation failed",
 	"msg_generating": "Generating...",
+	"form_verify": "Byte check",
+	"check_verify_bytes": "Compare byte by byte after hashing",
+	"label_prefix_match": "Prefix match only (not duplicates)",
+	"check_per_device_io": "One reader per disk (HDD)",
+	"btn_pause": "Pause",
+	"btn_resume": "Resume",
+	"btn_stop": "Stop",
+	"status_paused": "Status: Paused",
+	"status_cancelled": "Status: Stopped (results incomplete)",
+	"form_hash_cache": "Hash cache",
+	"check_hash_cache": "Reuse hashes of unchanged files",
+	"label_hash_cache": "Hash cache",
+	"btn_cache_stats": "Show Stats",
+	"btn_cache_prune": "Prune Stale Entries",
+	"msg_cache_stats": "%d entries | %d bytes | %s",
+	"msg_cache_pruned": "Pruned %d",
+	"tab_errors": "Errors",
+	"msg_no_scan_errors": "The last scan checked every file",
+	"msg_scan_errors": "Paths not checked: %d (%s)",
+	"label_hardlinked": "Already hardlinked (one copy)",
+	"label_links": "+%d hardlinks",
+	"form_symlinks": "Symlinks",
+	"form_detect_dirs": "Duplicate folders",
+	"check_detect_dirs": "Find identical and subset folders",
+	"form_archives": "Archives",
+	"check_archives": "Compare files inside zip/tar archives",
+	"form_image_matching": "Image matching",
+	"check_rotation_invariant": "Match rotated and mirrored copies",
+	"check_trim_borders": "Ignore borders and letterboxing",
+	"label_dir_identical": "Identical folders (%d file groups)",
+	"label_dir_subset": "Subset folder (%.0f%%)",
+	"form_extensions": "Extensions",
+	"placeholder_ext": "Only, e.g. jpg;png",
+	"placeholder_exclude_ext": "Skip, e.g. tmp;bak",
+	"form_modified": "Modified",
+	"placeholder_modified_after": "After YYYY-MM-DD",
+	"placeholder_modified_before": "Before YYYY-MM-DD",
+	"form_type_classes": "File types (by content)",
+	"class_image": "Images",
+	"class_video": "Videos",
+	"class_audio": "Audio",
+	"class_document": "Documents",
+	"class_archive": "Archives",
+	"class_code": "Code",
+	"form_attributes": "Attributes",
+	"check_skip_hidden": "Skip hidden files",
+	"check_skip_system": "Skip system files",
 }
 
 func t(state *AppState, key string) string {
@@ -32,21 +32,83 @@ func buildConfigPage(state *AppState, onStart func(cfg core.ScanConfig)) fyne.Ca
 		state.ExcludePatternsInput = v
 		state.mu.Unlock()
 	}
+	excludeEntry.Validator = validatePathRules
 
-	modeSelect := widget.NewSelect([]string{"basic", "video", "text", "image"}, func(v string) {
+	includeRulesEntry := widget.NewEntry()
+	includeRulesEntry.SetPlaceHolder(t(state, "placeholder_include_patterns"))
+	includeRulesEntry.OnChanged = func(v string) {
 		state.mu.Lock()
-		state.Mode = v
+		state.IncludePatternsInput = v
 		state.mu.Unlock()
-	})
+	}
+	includeRulesEntry.Validator = validatePathRules
+
+	// modes come from the engine registry; OnChanged is set once the option widgets exist
+	modeSelect := widget.NewSelect(core.EngineModes(), nil)
 	modeSelect.Selected = state.Mode
 
-	hashSelect := widget.NewSelect([]string{"sha1", "sha256", "md5"}, func(v string) {
+	hashSelect := widget.NewSelect(core.HasherNames(), func(v string) {
 		state.mu.Lock()
 		state.HashAlgorithm = v
 		state.mu.Unlock()
 	})
 	hashSelect.Selected = state.HashAlgorithm
 
+	phashSelect := widget.NewSelect(core.PerceptualHasherNames(), func(v string) {
+		state.mu.Lock()
+		state.PerceptualHash = v
+		state.mu.Unlock()
+	})
+	phashSelect.Selected = state.PerceptualHash
+
+	var clusterNames []string
+	for _, m := range core.ClusterMethods() {
+		clusterNames = append(clusterNames, string(m))
+	}
+	clusterSelect := widget.NewSelect(clusterNames, func(v string) {
+		state.mu.Lock()
+		state.Clustering = core.ClusterMethod(v)
+		state.mu.Unlock()
+	})
+	clusterSelect.Selected = string(state.Clustering)
+
+	var symlinkNames []string
+	for _, p := range core.SymlinkPolicies() {
+		symlinkNames = append(symlinkNames, string(p))
+	}
+	symlinkSelect := widget.NewSelect(symlinkNames, func(v string) {
+		state.mu.Lock()
+		state.SymlinkPolicy = core.SymlinkPolicy(v)
+		state.mu.Unlock()
+	})
+	symlinkSelect.Selected = string(state.SymlinkPolicy)
+
+	dirsCheck := widget.NewCheck(t(state, "check_detect_dirs"), func(v bool) {
+		state.mu.Lock()
+		state.DetectDirectories = v
+		state.mu.Unlock()
+	})
+	dirsCheck.Checked = state.DetectDirectories
+
+	archivesCheck := widget.NewCheck(t(state, "check_archives"), func(v bool) {
+		state.mu.Lock()
+		state.ScanArchives = v
+		state.mu.Unlock()
+	})
+	archivesCheck.Checked = state.ScanArchives
+	invariantCheck := widget.NewCheck(t(state, "check_rotation_invariant"), func(v bool) {
+		state.mu.Lock()
+		state.RotationInvariant = v
+		state.mu.Unlock()
+	})
+	invariantCheck.Checked = state.RotationInvariant
+	trimCheck := widget.NewCheck(t(state, "check_trim_borders"), func(v bool) {
+		state.mu.Lock()
+		state.TrimBorders = v
+		state.mu.Unlock()
+	})
+	trimCheck.Checked = state.TrimBorders
+
 	minEntry := widget.NewEntry()
 	minEntry.SetPlaceHolder(t(state, "placeholder_min_size"))
 	minEntry.OnChanged = func(v string) {
@@ -78,6 +140,83 @@ func buildConfigPage(state *AppState, onStart func(cfg core.ScanConfig)) fyne.Ca
 	}
 	concurrency.SetValue(float64(state.Concurrency))
 
+	perDeviceCheck := widget.NewCheck(t(state, "check_per_device_io"), func(v bool) {
+		state.mu.Lock()
+		if v {
+			state.PerDeviceIO = 1
+		} else {
+			state.PerDeviceIO = 0
+		}
+		state.mu.Unlock()
+	})
+	perDeviceCheck.Checked = state.PerDeviceIO > 0
+
+	extEntry := widget.NewEntry()
+	extEntry.SetPlaceHolder(t(state, "placeholder_ext"))
+	extEntry.OnChanged = func(v string) {
+		state.mu.Lock()
+		state.IncludeExtInput = v
+		state.mu.Unlock()
+	}
+	excludeExtEntry := widget.NewEntry()
+	excludeExtEntry.SetPlaceHolder(t(state, "placeholder_exclude_ext"))
+	excludeExtEntry.OnChanged = func(v string) {
+		state.mu.Lock()
+		state.ExcludeExtInput = v
+		state.mu.Unlock()
+	}
+
+	afterEntry := widget.NewEntry()
+	afterEntry.SetPlaceHolder(t(state, "placeholder_modified_after"))
+	afterEntry.Validator = validateTimeBound
+	afterEntry.OnChanged = func(v string) {
+		state.mu.Lock()
+		state.ModifiedAfterInput = v
+		state.mu.Unlock()
+	}
+	beforeEntry := widget.NewEntry()
+	beforeEntry.SetPlaceHolder(t(state, "placeholder_modified_before"))
+	beforeEntry.Validator = validateTimeBound
+	beforeEntry.OnChanged = func(v string) {
+		state.mu.Lock()
+		state.ModifiedBeforeInput = v
+		state.mu.Unlock()
+	}
+
+	classNames := make([]string, 0, len(core.FileClasses()))
+	classByName := map[string]core.FileClass{}
+	for _, c := range core.FileClasses() {
+		name := t(state, "class_"+string(c))
+		classNames = append(classNames, name)
+		classByName[name] = c
+	}
+	classGroup := widget.NewCheckGroup(classNames, func(selected []string) {
+		classes := make([]core.FileClass, 0, len(selected))
+		for _, name := range selected {
+			classes = append(classes, classByName[name])
+		}
+		state.mu.Lock()
+		state.TypeClasses = classes
+		state.mu.Unlock()
+	})
+	classGroup.Horizontal = true
+	for _, c := range state.TypeClasses {
+		classGroup.Selected = append(classGroup.Selected, t(state, "class_"+string(c)))
+	}
+
+	hiddenCheck := widget.NewCheck(t(state, "check_skip_hidden"), func(v bool) {
+		state.mu.Lock()
+		state.SkipHidden = v
+		state.mu.Unlock()
+	})
+	hiddenCheck.Checked = state.SkipHidden
+	systemCheck := widget.NewCheck(t(state, "check_skip_system"), func(v bool) {
+		state.mu.Lock()
+		state.SkipSystem = v
+		state.mu.Unlock()
+	})
+	systemCheck.Checked = state.SkipSystem
+
 	// similarity slider 0.50~0.99
 	simSlider := widget.NewSlider(0.5, 0.99)
 	simSlider.Step = 0.01
@@ -90,6 +229,58 @@ func buildConfigPage(state *AppState, onStart func(cfg core.ScanConfig)) fyne.Ca
 	}
 	simSlider.SetValue(state.SimilarityThreshold)
 
+	verifyCheck := widget.NewCheck(t(state, "check_verify_bytes"), func(v bool) {
+		state.mu.Lock()
+		state.VerifyBytes = v
+		state.mu.Unlock()
+	})
+	verifyCheck.Checked = state.VerifyBytes
+	simRow := container.NewHBox(simSlider, simLabel)
+
+	// only the options the selected mode honours stay editable
+	applyMode := func(mode string) {
+		info, err := core.LookupEngine(mode)
+		if err != nil {
+			return
+		}
+		for field, w := range map[string]fyne.Disableable{
+			"HashAlgorithm":     hashSelect,
+			"PerceptualHash":    phashSelect,
+			"Clustering":        clusterSelect,
+			"VerifyBytes":       verifyCheck,
+			"DetectDirectories": dirsCheck,
+			"ScanArchives":      archivesCheck,
+			"RotationInvariant": invariantCheck,
+			"TrimBorders":       trimCheck,
+		} {
+			if info.HasOption(field) {
+				w.Enable()
+			} else {
+				w.Disable()
+			}
+		}
+		// fyne's Slider cannot be disabled
+		if info.HasOption("SimilarityThreshold") {
+			simRow.Show()
+		} else {
+			simRow.Hide()
+		}
+	}
+	modeSelect.OnChanged = func(v string) {
+		state.mu.Lock()
+		state.Mode = v
+		state.mu.Unlock()
+		applyMode(v)
+	}
+	applyMode(state.Mode)
+
+	cacheCheck := widget.NewCheck(t(state, "check_hash_cache"), func(v bool) {
+		state.mu.Lock()
+		state.UseHashCache = v
+		state.mu.Unlock()
+	})
+	cacheCheck.Checked = state.UseHashCache
+
 	startBtn := widget.NewButton(t(state, "btn_start_scan"), func() {
 		onStart(state.ToScanConfig())
 	})
@@ -114,26 +305,85 @@ func buildConfigPage(state *AppState, onStart func(cfg core.ScanConfig)) fyne.Ca
 		Items: []*widget.FormItem{
 			{Text: t(state, "form_include_paths"), Widget: includeEntry},
 			{Text: t(state, "form_exclude_patterns"), Widget: excludeEntry},
+			{Text: t(state, "form_include_patterns"), Widget: includeRulesEntry},
 			{Text: t(state, "form_mode"), Widget: modeSelect},
 			{Text: t(state, "form_hash_algorithm"), Widget: hashSelect},
+			{Text: t(state, "form_perceptual_hash"), Widget: phashSelect},
+			{Text: t(state, "form_clustering"), Widget: clusterSelect},
+			{Text: t(state, "form_image_matching"), Widget: container.NewHBox(invariantCheck, trimCheck)},
 			{Text: t(state, "form_min_size"), Widget: minEntry},
 			{Text: t(state, "form_max_size"), Widget: maxEntry},
-			{Text: t(state, "form_concurrency"), Widget: container.NewHBox(concurrency, cLabel)},
-			{Text: t(state, "form_similarity"), Widget: container.NewHBox(simSlider, simLabel)},
+			{Text: t(state, "form_extensions"), Widget: container.NewGridWithColumns(2, extEntry, excludeExtEntry)},
+			{Text: t(state, "form_modified"), Widget: container.NewGridWithColumns(2, afterEntry, beforeEntry)},
+			{Text: t(state, "form_type_classes"), Widget: classGroup},
+			{Text: t(state, "form_attributes"), Widget: container.NewHBox(hiddenCheck, systemCheck)},
+			{Text: t(state, "form_concurrency"), Widget: container.NewHBox(concurrency, cLabel, perDeviceCheck)},
+			{Text: t(state, "form_similarity"), Widget: simRow},
+			{Text: t(state, "form_verify"), Widget: verifyCheck},
+			{Text: t(state, "form_hash_cache"), Widget: cacheCheck},
+			{Text: t(state, "form_symlinks"), Widget: symlinkSelect},
+			{Text: t(state, "form_detect_dirs"), Widget: dirsCheck},
+			{Text: t(state, "form_archives"), Widget: archivesCheck},
 		},
 		OnSubmit: func() { onStart(state.ToScanConfig()) },
 	}
 	return container.NewBorder(nil, container.NewHBox(pickDirBtn, startBtn), nil, nil, form)
 }
 
+// validatePathRules flags exclude/include patterns the scanner would reject.
+func validatePathRules(v string) error {
+	_, err := core.ParsePathRules(splitSemicolon(v))
+	return err
+}
+
+func validateTimeBound(v string) error {
+	_, err := core.ParseTimeBound(v)
+	return err
+}
+
 // buildMonitorPage shows minimal statistics
 func buildMonitorPage(state *AppState) fyne.CanvasObject {
 	files := widget.NewLabel(fmt.Sprintf("%s 0", t(state, "label_files")))
 	groups := widget.NewLabel(fmt.Sprintf("%s 0", t(state, "label_groups")))
 	status := widget.NewLabel(t(state, "status_idle"))
 	speed := widget.NewLabel(fmt.Sprintf("%s -", t(state, "label_speed")))
+	stage := widget.NewLabel(fmt.Sprintf(t(state, "label_stage"), "-"))
+	bar := widget.NewProgressBar()
+	eta := widget.NewLabel(fmt.Sprintf(t(state, "label_eta"), t(state, "eta_unknown")))
+	current := widget.NewLabel(fmt.Sprintf(t(state, "label_current"), ""))
+	current.Truncation = fyne.TextTruncateEllipsis
 
-	box := container.NewVBox(files, groups, status, speed)
+	var pauseBtn *widget.Button
+	pauseBtn = widget.NewButton(t(state, "btn_pause"), func() {
+		state.mu.RLock()
+		p := state.ScanPauser
+		state.mu.RUnlock()
+		if p == nil {
+			return
+		}
+		if p.Paused() {
+			p.Resume()
+			pauseBtn.SetText(t(state, "btn_pause"))
+		} else {
+			p.Pause()
+			pauseBtn.SetText(t(state, "btn_resume"))
+		}
+	})
+	stopBtn := widget.NewButton(t(state, "btn_stop"), func() {
+		state.mu.RLock()
+		cancel := state.ScanCancel
+		p := state.ScanPauser
+		state.mu.RUnlock()
+		if cancel != nil {
+			cancel()
+		}
+		// a paused scan must wake up to observe the cancellation
+		if p != nil {
+			p.Resume()
+		}
+	})
+
+	box := container.NewVBox(files, groups, status, stage, bar, speed, eta, current, container.NewHBox(pauseBtn, stopBtn))
 
 	go func() {
 		var lastFiles int
@@ -144,21 +394,57 @@ func buildMonitorPage(state *AppState) fyne.CanvasObject {
 			f := state.FilesScanned
 			g := state.GroupsFound
 			scanning := state.IsScanning
+			incomplete := state.ScanIncomplete
+			paused := state.ScanPauser.Paused()
+			p := state.Progress
 			state.mu.RUnlock()
 
 			files.SetText(fmt.Sprintf("%s %d", t(state, "label_files"), f))
 			groups.SetText(fmt.Sprintf("%s %d", t(state, "label_groups"), g))
-			if scanning {
+			switch {
+			case scanning && paused:
+				status.SetText(t(state, "status_paused"))
+			case scanning:
 				status.SetText(t(state, "status_scanning"))
-			} else {
+			case incomplete:
+				status.SetText(t(state, "status_cancelled"))
+			default:
 				status.SetText(t(state, "status_idle"))
 			}
+			if scanning {
+				pauseBtn.Enable()
+				stopBtn.Enable()
+			} else {
+				pauseBtn.SetText(t(state, "btn_pause"))
+				pauseBtn.Disable()
+				stopBtn.Disable()
+			}
+
+			stage.SetText(fmt.Sprintf(t(state, "label_stage"), p.Stage))
+			switch {
+			case p.StagePercent >= 0:
+				bar.SetValue(p.StagePercent / 100)
+			case p.Stage == "done":
+				bar.SetValue(1)
+			default:
+				bar.SetValue(0) // workload of the walk is unknown
+			}
+			etaText := t(state, "eta_unknown")
+			if scanning && p.ETA > 0 {
+				etaText = p.ETA.Round(time.Second).String()
+			}
+			eta.SetText(fmt.Sprintf(t(state, "label_eta"), etaText))
+			if scanning {
+				current.SetText(fmt.Sprintf(t(state, "label_current"), p.CurrentPath))
+			} else {
+				current.SetText(fmt.Sprintf(t(state, "label_current"), ""))
+			}
 
 			now := time.Now()
 			dt := now.Sub(lastTime).Seconds()
 			if dt > 0 {
 				spd := float64(f-lastFiles) / dt
-				speed.SetText(fmt.Sprintf("%s %.1f %s", t(state, "label_speed"), spd, t(state, "speed_unit")))
+				speed.SetText(fmt.Sprintf("%s %.1f %s | %.1f MB/s", t(state, "label_speed"), spd, t(state, "speed_unit"), p.BytesPerSecond/(1<<20)))
 			}
 			lastFiles = f
 			lastTime = now
@@ -191,8 +477,24 @@ func buildResultsPage(state *AppState) fyne.CanvasObject {
 				return
 			}
 			g := state.Results[i]
-			sim := core.EstimateGroupSimilarity(g.Files)
-			o.(*widget.Label).SetText(fmt.Sprintf("组 %d | 文件数 %d | 相似度 %.0f%%", i+1, len(g.Files), sim))
+			switch g.Kind {
+			case core.MatchPrefix:
+				o.(*widget.Label).SetText(fmt.Sprintf("组 %d | 文件数 %d | %s", i+1, len(g.Files), t(state, "label_prefix_match")))
+				return
+			case core.MatchHardlink:
+				o.(*widget.Label).SetText(fmt.Sprintf("组 %d | 路径数 %d | %s", i+1, len(g.Files[0].Paths()), t(state, "label_hardlinked")))
+				return
+			case core.MatchSymlink:
+				o.(*widget.Label).SetText(fmt.Sprintf("组 %d | %s -> %s", i+1, g.Files[0].Path, g.Files[0].LinkTarget))
+				return
+			case core.MatchDirectory:
+				o.(*widget.Label).SetText(fmt.Sprintf("组 %d | 目录数 %d | "+t(state, "label_dir_identical"), i+1, len(g.Files), len(g.Collapsed)))
+				return
+			case core.MatchDirSubset:
+				o.(*widget.Label).SetText(fmt.Sprintf("组 %d | %s ⊂ %s | "+t(state, "label_dir_subset"), i+1, g.Files[1].Path, g.Files[0].Path, g.Similarity*100))
+				return
+			}
+			o.(*widget.Label).SetText(fmt.Sprintf("组 %d | 文件数 %d | 相似度 %.0f%%", i+1, len(g.Files), g.Similarity*100))
 		},
 	)
 
@@ -205,20 +507,9 @@ func buildResultsPage(state *AppState) fyne.CanvasObject {
 		case t(state, "sort_files_desc"):
 			sort.Slice(state.Results, func(i, j int) bool { return len(state.Results[i].Files) > len(state.Results[j].Files) })
 		case t(state, "sort_size_desc"):
-			size := func(g core.DuplicateGroup) int64 {
-				var s int64
-				for _, f := range g.Files {
-					s += f.SizeBytes
-				}
-				return s
-			}
-			sort.Slice(state.Results, func(i, j int) bool { return size(state.Results[i]) > size(state.Results[j]) })
+			sort.Slice(state.Results, func(i, j int) bool { return state.Results[i].TotalBytes > state.Results[j].TotalBytes })
 		case t(state, "sort_similarity_desc"):
-			sort.Slice(state.Results, func(i, j int) bool {
-				si := core.EstimateGroupSimilarity(state.Results[i].Files)
-				sj := core.EstimateGroupSimilarity(state.Results[j].Files)
-				return si > sj
-			})
+			sort.Slice(state.Results, func(i, j int) bool { return state.Results[i].Similarity > state.Results[j].Similarity })
 		}
 		state.mu.Unlock()
 		groupsList.Refresh()
@@ -233,8 +524,7 @@ func buildResultsPage(state *AppState) fyne.CanvasObject {
 		}
 		g := state.Results[id]
 		state.mu.RUnlock()
-		sim := core.EstimateGroupSimilarity(g.Files)
-		groupTitle.SetText(fmt.Sprintf("组 %d 详情： 相似度≈%.0f%%", id+1, sim))
+		groupTitle.SetText(fmt.Sprintf("组 %d 详情： 相似度≈%.0f%%", id+1, g.Similarity*100))
 		files := g.Files
 		filesList.Length = func() int { return len(files) }
 		filesList.UpdateItem = func(i widget.ListItemID, o fyne.CanvasObject) {
@@ -242,7 +532,14 @@ func buildResultsPage(state *AppState) fyne.CanvasObject {
 				return
 			}
 			f := files[i]
-			o.(*widget.Label).SetText(fmt.Sprintf("%s | %dB", f.Path, f.SizeBytes))
+			text := fmt.Sprintf("%s | %dB", f.Path, f.SizeBytes)
+			if f.Type != "" {
+				text += " | " + f.Type
+			}
+			if len(f.Links) > 0 {
+				text += " | " + fmt.Sprintf(t(state, "label_links"), len(f.Links))
+			}
+			o.(*widget.Label).SetText(text)
 		}
 		filesList.Refresh()
 		thumbError.SetText("")
@@ -292,6 +589,9 @@ func buildResultsPage(state *AppState) fyne.CanvasObject {
 		thumbGrid.Refresh()
 	}
 
+	// follow the scan: redraw the list whenever groups arrive or the final results replace them
+	state.OnResultsChanged("results", groupsList.Refresh)
+
 	thumbBox := container.NewMax()
 	if thumbImg != nil {
 		thumbBox.Add(thumbImg)
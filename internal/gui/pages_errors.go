@@ -0,0 +1,54 @@
+package gui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"goduplicate/internal/core"
+)
+
+// buildErrorsPage lists the paths the last scan could not check, grouped by category.
+func buildErrorsPage(state *AppState) fyne.CanvasObject {
+	var errs []core.ScanError
+	summary := widget.NewLabel(t(state, "msg_no_scan_errors"))
+	list := widget.NewList(
+		func() int { return len(errs) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			if i < 0 || i >= len(errs) {
+				return
+			}
+			e := errs[i]
+			o.(*widget.Label).SetText(fmt.Sprintf("[%s/%s] %s: %s", e.Category, e.Stage, e.Path, e.Message))
+		},
+	)
+
+	refresh := func() {
+		state.mu.RLock()
+		report := state.LastReport
+		state.mu.RUnlock()
+		errs = report.Sorted()
+		if len(errs) == 0 {
+			summary.SetText(t(state, "msg_no_scan_errors"))
+		} else {
+			counts := report.ByCategory()
+			parts := make([]string, 0, len(counts))
+			for c, n := range counts {
+				parts = append(parts, fmt.Sprintf("%s=%d", c, n))
+			}
+			sort.Strings(parts)
+			summary.SetText(fmt.Sprintf(t(state, "msg_scan_errors"), len(errs), strings.Join(parts, ", ")))
+		}
+		list.Refresh()
+	}
+	refresh()
+
+	state.OnResultsChanged("errors", refresh)
+
+	return container.NewBorder(summary, nil, nil, nil, list)
+}
@@ -1,6 +1,9 @@
 package gui
 
 import (
+	"fmt"
+	"time"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/theme"
@@ -58,12 +61,29 @@ func buildSettingsPage(state *AppState) fyne.CanvasObject {
 			state.mu.Lock()
 			state.IncludePathsInput = joinWithSemicolon(p.Config.IncludePaths)
 			state.ExcludePatternsInput = joinWithSemicolon(p.Config.ExcludePatterns)
+			state.IncludePatternsInput = joinWithSemicolon(p.Config.IncludePatterns)
 			state.Mode = p.Config.Mode
 			state.Concurrency = p.Config.Concurrency
+			state.PerDeviceIO = p.Config.PerDeviceIO
 			state.MinSizeBytes = p.Config.MinSizeBytes
 			state.MaxSizeBytes = p.Config.MaxSizeBytes
+			state.IncludeExtInput = joinWithSemicolon(p.Config.IncludeExtensions)
+			state.ExcludeExtInput = joinWithSemicolon(p.Config.ExcludeExtensions)
+			state.ModifiedAfterInput = formatTimeBound(p.Config.ModifiedAfterUnix)
+			state.ModifiedBeforeInput = formatTimeBound(p.Config.ModifiedBeforeUnix)
+			state.TypeClasses = p.Config.TypeClasses
+			state.SkipHidden = p.Config.SkipHidden
+			state.SkipSystem = p.Config.SkipSystem
 			state.HashAlgorithm = p.Config.HashAlgorithm
+			state.PerceptualHash = p.Config.PerceptualHash
+			state.Clustering = p.Config.Clustering
+			state.RotationInvariant = p.Config.RotationInvariant
+			state.TrimBorders = p.Config.TrimBorders
 			state.SimilarityThreshold = p.Config.SimilarityThreshold
+			state.VerifyBytes = p.Config.VerifyBytes
+			state.SymlinkPolicy = p.Config.SymlinkPolicy
+			state.DetectDirectories = p.Config.DetectDirectories
+			state.ScanArchives = p.Config.ScanArchives
 			state.mu.Unlock()
 		}
 	})
@@ -77,6 +97,32 @@ func buildSettingsPage(state *AppState) fyne.CanvasObject {
 	})
 	deletePresetBtn := widget.NewButton(t(state, "btn_delete_preset"), func() { _ = core.DeleteScanPreset(presetList.Selected) })
 
+	cacheInfo := widget.NewLabel("")
+	showCacheStats := func() {
+		idx, err := core.OpenHashIndex(core.DefaultHashIndexPath())
+		if err != nil {
+			cacheInfo.SetText(err.Error())
+			return
+		}
+		st := idx.Stats()
+		cacheInfo.SetText(fmt.Sprintf(t(state, "msg_cache_stats"), st.Entries, st.FileBytes, st.Path))
+	}
+	cacheStatsBtn := widget.NewButton(t(state, "btn_cache_stats"), showCacheStats)
+	cachePruneBtn := widget.NewButton(t(state, "btn_cache_prune"), func() {
+		idx, err := core.OpenHashIndex(core.DefaultHashIndexPath())
+		if err != nil {
+			cacheInfo.SetText(err.Error())
+			return
+		}
+		n, err := idx.Prune(0)
+		if err != nil {
+			cacheInfo.SetText(err.Error())
+			return
+		}
+		showCacheStats()
+		cacheInfo.SetText(fmt.Sprintf(t(state, "msg_cache_pruned"), n) + " | " + cacheInfo.Text)
+	})
+
 	return container.NewVBox(
 		widget.NewForm(
 			widget.NewFormItem(t(state, "label_theme"), theme),
@@ -93,6 +139,10 @@ func buildSettingsPage(state *AppState) fyne.CanvasObject {
 			widget.NewFormItem(t(state, "label_refresh"), refreshListBtn),
 			widget.NewFormItem(t(state, "label_delete"), deletePresetBtn),
 		),
+		widget.NewForm(
+			widget.NewFormItem(t(state, "label_hash_cache"), container.NewHBox(cacheStatsBtn, cachePruneBtn)),
+		),
+		cacheInfo,
 	)
 }
 
@@ -110,3 +160,15 @@ func joinWithSemicolon(items []string) string {
 	}
 	return out
 }
+
+// formatTimeBound is the inverse of core.ParseTimeBound for the date fields of the form.
+func formatTimeBound(unix int64) string {
+	if unix <= 0 {
+		return ""
+	}
+	t := time.Unix(unix, 0)
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
@@ -1,6 +1,7 @@
 package gui
 
 import (
+	"context"
 	"image"
 	"sync"
 
@@ -17,25 +18,52 @@ type AppState struct {
 	// Live configuration fields bound to the config form
 	IncludePathsInput    string // semicolon separated
 	ExcludePatternsInput string // semicolon separated
+	IncludePatternsInput string // semicolon separated
 	Mode                 string
 	Concurrency          int
+	PerDeviceIO          int
 	MinSizeBytes         int64
 	MaxSizeBytes         int64
+	IncludeExtInput      string // semicolon separated
+	ExcludeExtInput      string // semicolon separated
+	ModifiedAfterInput   string // YYYY-MM-DD[ HH:MM], empty for no bound
+	ModifiedBeforeInput  string // YYYY-MM-DD[ HH:MM], empty for no bound
+	TypeClasses          []core.FileClass
+	SkipHidden           bool
+	SkipSystem           bool
 	HashAlgorithm        string
+	PerceptualHash       string
+	Clustering           core.ClusterMethod
+	RotationInvariant    bool
+	TrimBorders          bool
 	SimilarityThreshold  float64
+	VerifyBytes          bool
+	UseHashCache         bool
+	SymlinkPolicy        core.SymlinkPolicy
+	DetectDirectories    bool
+	ScanArchives         bool
 
 	// Scan results and stats
 	Results       []core.DuplicateGroup
 	LastScanError error
+	LastReport    *core.ScanReport // paths the last scan could not check
+	// pages to refresh when Results or LastReport change, by page
+	resultsListeners map[string]func()
 
 	// Strategy & execution
 	Plan []core.PlanItem
 	Logs []string
 
 	// Monitoring snapshot
-	FilesScanned int
-	GroupsFound  int
-	IsScanning   bool
+	FilesScanned   int
+	GroupsFound    int
+	Progress       core.Progress // latest progress report of the running scan
+	IsScanning     bool
+	ScanIncomplete bool // last scan was cancelled
+
+	// Control of the running scan
+	ScanCancel context.CancelFunc
+	ScanPauser *core.Pauser
 
 	// Settings (placeholder)
 	Theme      string // light|dark
@@ -53,8 +81,11 @@ func NewAppState() *AppState {
 	return &AppState{
 		Mode:                "basic",
 		Concurrency:         4,
-		HashAlgorithm:       "sha1",
+		HashAlgorithm:       core.DefaultHashAlgorithm,
+		PerceptualHash:      core.DefaultPerceptualHash,
+		Clustering:          core.ClusterComponents,
 		SimilarityThreshold: 0.85,
+		SymlinkPolicy:       core.SymlinksIgnore,
 		Theme:               "light",
 		Language:            "zh-CN", // 默认设置为中文
 		ThumbCache:          make(map[string]image.Image),
@@ -64,15 +95,35 @@ func NewAppState() *AppState {
 func (s *AppState) ToScanConfig() core.ScanConfig {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	// invalid dates are flagged by the form validators; here they simply mean no bound
+	after, _ := core.ParseTimeBound(s.ModifiedAfterInput)
+	before, _ := core.ParseTimeBound(s.ModifiedBeforeInput)
 	return core.ScanConfig{
 		IncludePaths:        splitSemicolon(s.IncludePathsInput),
 		ExcludePatterns:     splitSemicolon(s.ExcludePatternsInput),
+		IncludePatterns:     splitSemicolon(s.IncludePatternsInput),
 		Mode:                s.Mode,
 		Concurrency:         s.Concurrency,
+		PerDeviceIO:         s.PerDeviceIO,
 		MinSizeBytes:        s.MinSizeBytes,
 		MaxSizeBytes:        s.MaxSizeBytes,
+		IncludeExtensions:   splitSemicolon(s.IncludeExtInput),
+		ExcludeExtensions:   splitSemicolon(s.ExcludeExtInput),
+		ModifiedAfterUnix:   after,
+		ModifiedBeforeUnix:  before,
+		TypeClasses:         append([]core.FileClass(nil), s.TypeClasses...),
+		SkipHidden:          s.SkipHidden,
+		SkipSystem:          s.SkipSystem,
 		HashAlgorithm:       s.HashAlgorithm,
+		PerceptualHash:      s.PerceptualHash,
+		Clustering:          s.Clustering,
+		RotationInvariant:   s.RotationInvariant,
+		TrimBorders:         s.TrimBorders,
 		SimilarityThreshold: s.SimilarityThreshold,
+		VerifyBytes:         s.VerifyBytes,
+		SymlinkPolicy:       s.SymlinkPolicy,
+		DetectDirectories:   s.DetectDirectories,
+		ScanArchives:        s.ScanArchives,
 	}
 }
 
@@ -112,6 +163,31 @@ func (s *AppState) TriggerLanguageChangedCallbacks() {
 	}
 }
 
+// OnResultsChanged registers fn to run whenever Results or LastReport change. A page registers
+// under its own key, so a rebuilt page replaces the listener of the one it succeeds.
+func (s *AppState) OnResultsChanged(page string, fn func()) {
+	s.mu.Lock()
+	if s.resultsListeners == nil {
+		s.resultsListeners = map[string]func(){}
+	}
+	s.resultsListeners[page] = fn
+	s.mu.Unlock()
+}
+
+// NotifyResultsChanged runs the listeners registered with OnResultsChanged; call it after
+// releasing mu.
+func (s *AppState) NotifyResultsChanged() {
+	s.mu.RLock()
+	listeners := make([]func(), 0, len(s.resultsListeners))
+	for _, fn := range s.resultsListeners {
+		listeners = append(listeners, fn)
+	}
+	s.mu.RUnlock()
+	for _, fn := range listeners {
+		fn()
+	}
+}
+
 func trimSpaces(s string) string {
 	// simple local trim to avoid bringing strings here
 	i := 0